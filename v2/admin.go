@@ -0,0 +1,67 @@
+package micro
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// AddAdminEndpoint registers the handler for the given pattern on the internal admin server.
+// Admin endpoints are only served when an admin port is set in config and are never exposed on the service port.
+func (service *Service) AddAdminEndpoint(pattern string, handler http.Handler) {
+	if service.adminMux == nil {
+		service.adminMux = http.NewServeMux()
+	}
+	service.adminMux.Handle(pattern, handler)
+}
+
+// AddAdminEndpointFunc registers the handler function for the given pattern on the internal admin server.
+func (service *Service) AddAdminEndpointFunc(pattern string, handleFunc http.HandlerFunc) {
+	if service.adminMux == nil {
+		service.adminMux = http.NewServeMux()
+	}
+	service.adminMux.HandleFunc(pattern, handleFunc)
+}
+
+// AdminServeMux returns the HTTP request multiplexer for the internal admin server
+func (service *Service) AdminServeMux() *http.ServeMux {
+	if service.adminMux == nil {
+		service.adminMux = http.NewServeMux()
+	}
+	return service.adminMux
+}
+
+// startAdminServer starts serving admin endpoints on the admin port.
+// It returns a nil server if no admin port is configured.
+func (service *Service) startAdminServer(errChan chan<- error) (*http.Server, error) {
+	if service.cfg.AdminPort() == 0 {
+		if service.adminMux != nil {
+			service.logger.Warning("admin endpoints registered but no admin port configured, they will not be served")
+		}
+		return nil, nil
+	}
+
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", service.cfg.AdminPort()))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create TCP listener for admin server")
+	}
+
+	adminServer := &http.Server{
+		Addr:    fmt.Sprintf(":%d", service.cfg.AdminPort()),
+		Handler: service.AdminServeMux(),
+	}
+
+	go func() {
+		err := adminServer.Serve(lis)
+		if errors.Is(err, http.ErrServerClosed) {
+			err = nil
+		}
+		errChan <- errors.Wrap(err, "admin server stopped")
+	}()
+
+	service.logger.Infof("<ADMIN> server running on port %d (insecure)", service.cfg.AdminPort())
+
+	return adminServer, nil
+}
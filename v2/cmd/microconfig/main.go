@@ -0,0 +1,146 @@
+// Command microconfig prints and validates service config loaded the same way as config.New.
+//
+// Usage:
+//
+//	microconfig dump [--config-file configs/config.yml] [--profile staging] [--set path=value] [--format yaml|json|toml]
+//	microconfig validate [--config-file configs/config.yml] [--profile staging] [--set path=value]
+//	microconfig schema [-o config.schema.json]
+//
+// dump prints the effective config after files, profiles, secrets and overrides are merged, with secrets redacted.
+// validate reports every validation error and exits with status 1 if the config is not valid.
+// schema prints the JSON Schema of config files, e.g for editor support.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/gidyon/micro/v2/pkg/config"
+)
+
+const usage = `Usage: microconfig <command> [flags]
+
+Commands:
+  dump      print the effective config with secrets redacted
+  validate  check the config and report all validation errors
+  schema    print the JSON Schema of config files
+
+Run microconfig <command> -h for the flags of a command.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	cmd := args[0]
+
+	if cmd == "schema" {
+		return schema(args[1:], stdout, stderr)
+	}
+
+	fs := flag.NewFlagSet("microconfig "+cmd, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	opt := &config.Options{SkipValidation: true}
+	opt.BindFlags(fs)
+
+	var format *string
+
+	switch cmd {
+	case "dump":
+		format = fs.String("format", config.FormatYAML, "Output format, yaml, json or toml")
+	case "validate":
+	case "-h", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", cmd, usage)
+		return 2
+	}
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	cfg, err := config.Load(opt)
+	if err != nil {
+		fmt.Fprintf(stderr, "failed to load config: %v\n", err)
+		return 1
+	}
+
+	switch cmd {
+	case "dump":
+		if err := cfg.Validate(); err != nil {
+			fmt.Fprintf(stderr, "warning: config is not valid: %v\n", err)
+		}
+		if err := cfg.Dump(stdout, *format); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	case "validate":
+		return validate(cfg, stdout, stderr)
+	}
+
+	return 0
+}
+
+func validate(cfg *config.Config, stdout, stderr io.Writer) int {
+	err := cfg.Validate()
+	if err == nil {
+		fmt.Fprintln(stdout, "config is valid")
+		return 0
+	}
+
+	validationErr := &config.ValidationError{}
+	if !errors.As(err, &validationErr) {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	fmt.Fprintf(stderr, "config has %d validation errors:\n", len(validationErr.Errors))
+	for _, fieldErr := range validationErr.Errors {
+		fmt.Fprintf(stderr, "  %s\n", fieldErr)
+	}
+
+	return 1
+}
+
+func schema(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("microconfig schema", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+
+	output := fs.String("o", "", "Write the schema to this file instead of stdout")
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	bs, err := config.JSONSchema()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	bs = append(bs, '\n')
+
+	if *output == "" {
+		_, err = stdout.Write(bs)
+	} else {
+		err = ioutil.WriteFile(*output, bs, 0644)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	return 0
+}
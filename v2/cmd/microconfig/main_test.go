@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantOut    []string
+		wantErr    []string
+		notWantOut []string
+	}{
+		{
+			name:     "validate valid config",
+			args:     []string{"validate", "--config-file", "testdata/valid.yml"},
+			wantCode: 0,
+			wantOut:  []string{"config is valid"},
+		},
+		{
+			name:     "validate invalid config",
+			args:     []string{"validate", "--config-file", "testdata/invalid.yml"},
+			wantCode: 1,
+			wantErr:  []string{"config has 1 validation errors", "auth.signingKey: value is required"},
+		},
+		{
+			name:     "validate missing config",
+			args:     []string{"validate", "--config-file", "testdata/missing.yml"},
+			wantCode: 1,
+			wantErr:  []string{"failed to load config"},
+		},
+		{
+			name:       "dump redacts secrets",
+			args:       []string{"dump", "--config-file", "testdata/valid.yml"},
+			wantCode:   0,
+			wantOut:    []string{"serviceName: orders", "password: REDACTED", "signingKey: REDACTED"},
+			notWantOut: []string{"db-s3cret", "signing-s3cret"},
+		},
+		{
+			name:       "dump json with override",
+			args:       []string{"dump", "--config-file", "testdata/valid.yml", "--format", "json", "--set", "httpPort=9090"},
+			wantCode:   0,
+			wantOut:    []string{`"httpPort": 9090`},
+			notWantOut: []string{"db-s3cret", "signing-s3cret"},
+		},
+		{
+			name:     "dump invalid config",
+			args:     []string{"dump", "--config-file", "testdata/invalid.yml"},
+			wantCode: 0,
+			wantOut:  []string{"serviceName: orders"},
+			wantErr:  []string{"warning: config is not valid"},
+		},
+		{name: "no command", wantCode: 2, wantErr: []string{"Usage: microconfig"}},
+		{name: "unknown command", args: []string{"lint"}, wantCode: 2, wantErr: []string{`unknown command "lint"`}},
+		{name: "unknown flag", args: []string{"validate", "--nope"}, wantCode: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+			code := run(tt.args, stdout, stderr)
+			if code != tt.wantCode {
+				t.Fatalf("run() = %d, want %d\nstdout: %s\nstderr: %s", code, tt.wantCode, stdout, stderr)
+			}
+
+			for _, want := range tt.wantOut {
+				if !strings.Contains(stdout.String(), want) {
+					t.Errorf("stdout does not contain %q:\n%s", want, stdout)
+				}
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(stderr.String(), want) {
+					t.Errorf("stderr does not contain %q:\n%s", want, stderr)
+				}
+			}
+			for _, notWant := range tt.notWantOut {
+				if strings.Contains(stdout.String(), notWant) {
+					t.Errorf("stdout contains %q:\n%s", notWant, stdout)
+				}
+			}
+		})
+	}
+}
+
+func TestRunSchema(t *testing.T) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+
+	code := run([]string{"schema"}, stdout, stderr)
+	if code != 0 {
+		t.Fatalf("run() = %d, want 0\nstderr: %s", code, stderr)
+	}
+
+	schema := map[string]interface{}{}
+	err := json.Unmarshal(stdout.Bytes(), &schema)
+	if err != nil {
+		t.Fatalf("schema is not valid JSON: %v\n%s", err, stdout)
+	}
+
+	props, _ := schema["properties"].(map[string]interface{})
+	if _, ok := props["serviceName"]; !ok {
+		t.Errorf("schema properties do not contain serviceName: %v", props)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/gidyon/micro/v2/pkg/config"
 	"github.com/gidyon/micro/v2/pkg/conn"
 	redis "github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
 	"google.golang.org/grpc"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
@@ -55,10 +56,14 @@ func (service *Service) openSQLDBConnections(ctx context.Context) error {
 			User:     sqlDBInfo.User(),
 			Password: sqlDBInfo.Password(),
 			Schema:   sqlDBInfo.Schema(),
-			ConnPool: poolOptions,
+			// Postgres options
+			SSLMode:    sqlDBInfo.Metadata().SSLMode(),
+			SearchPath: sqlDBInfo.Metadata().SearchPath(),
+			TimeZone:   sqlDBInfo.Metadata().TimeZone(),
+			ConnPool:   poolOptions,
 		})
 		if err != nil {
-			return err
+			return errors.Wrapf(err, "failed to open sql database [name: %s]", clientName)
 		}
 
 		service.sqlDBs[clientName] = sqlDB
@@ -67,24 +72,30 @@ func (service *Service) openSQLDBConnections(ctx context.Context) error {
 
 		// open gorm connection
 		switch strings.ToLower(sqlDBInfo.SQLDatabaseDialect()) {
-		case "postgres":
+		case conn.DialectPostgres:
 			gormDB, err = gorm.Open(postgres.New(postgres.Config{
 				Conn: sqlDB,
 			}), &gorm.Config{
 				NowFunc: service.nowFunc,
+				// Availability is checked by waitForDependencies after every connection is opened
+				DisableAutomaticPing: true,
 			})
 			if err != nil {
-				return err
+				return errors.Wrapf(err, "failed to open gorm postgres database [name: %s]", clientName)
 			}
 		default:
 			// mysql connection
 			gormDB, err = gorm.Open(mysql.New(mysql.Config{
 				Conn: sqlDB,
+				// Querying the server version would connect before the database is waited for
+				SkipInitializeWithVersion: true,
 			}), &gorm.Config{
 				NowFunc: service.nowFunc,
+				// Availability is checked by waitForDependencies after every connection is opened
+				DisableAutomaticPing: true,
 			})
 			if err != nil {
-				return err
+				return errors.Wrapf(err, "failed to open gorm mysql database [name: %s]", clientName)
 			}
 		}
 
@@ -171,7 +182,7 @@ func (service *Service) openExternalConnections(ctx context.Context) error {
 
 		externalServices[name], err = service.DialExternalService(ctx, name, dopts...)
 		if err != nil {
-			return err
+			return errors.Wrapf(err, "failed to dial external service [name: %s]", srv.Name())
 		}
 
 		service.shutdowns = append(service.shutdowns, func() error {
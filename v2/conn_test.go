@@ -0,0 +1,149 @@
+package micro
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gidyon/micro/v2/internal/testutil"
+	"github.com/gidyon/micro/v2/pkg/config"
+)
+
+// servePostgres answers the postgres startup and simple query messages used to open a connection and ping it
+func servePostgres(c net.Conn) {
+	defer c.Close()
+
+	readN := func(n int) ([]byte, error) {
+		bs := make([]byte, n)
+		_, err := io.ReadFull(c, bs)
+		return bs, err
+	}
+
+	for {
+		header, err := readN(8)
+		if err != nil {
+			return
+		}
+		// SSL request code, postgres answers N when it does not support TLS
+		if binary.BigEndian.Uint32(header[4:]) != 80877103 {
+			if _, err := readN(int(binary.BigEndian.Uint32(header[:4])) - 8); err != nil {
+				return
+			}
+			break
+		}
+		if _, err := c.Write([]byte{'N'}); err != nil {
+			return
+		}
+	}
+
+	readyForQuery := []byte{'Z', 0, 0, 0, 5, 'I'}
+
+	// AuthenticationOk followed by ReadyForQuery
+	_, err := c.Write(append([]byte{'R', 0, 0, 0, 8, 0, 0, 0, 0}, readyForQuery...))
+	if err != nil {
+		return
+	}
+
+	for {
+		header, err := readN(5)
+		if err != nil {
+			return
+		}
+		if _, err := readN(int(binary.BigEndian.Uint32(header[1:])) - 4); err != nil {
+			return
+		}
+
+		switch header[0] {
+		case 'Q':
+			// EmptyQueryResponse for the ping query
+			_, err = c.Write(append([]byte{'I', 0, 0, 0, 4}, readyForQuery...))
+			if err != nil {
+				return
+			}
+		case 'X':
+			return
+		}
+	}
+}
+
+func TestInitWaitsForSQLDatabase(t *testing.T) {
+	const startAfter = 500 * time.Millisecond
+
+	dbPort := testutil.FreePort(t)
+
+	cfg, err := config.NewBuilder("test").
+		Insecure().
+		HTTPort(testutil.FreePort(t)).
+		GRPCPort(testutil.FreePort(t)).
+		Database(&config.DatabaseSpec{
+			Name:     "orders",
+			Type:     config.SQLDBType,
+			Dialect:  "postgres",
+			Address:  fmt.Sprintf("localhost:%d", dbPort),
+			User:     "test",
+			Password: "test",
+			Schema:   "orders",
+			Required: true,
+			SSLMode:  "disable",
+		}).
+		Set("databases.orders.waitFor.initialBackoffMillis", "100").
+		Set("databases.orders.waitFor.maxWaitSeconds", "10").
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := NewService(context.Background(), cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var conns int32
+
+	// The database only becomes reachable after the first probe has failed
+	go func() {
+		time.Sleep(startAfter)
+
+		lis, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", dbPort))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		t.Cleanup(func() { lis.Close() })
+
+		for {
+			c, err := lis.Accept()
+			if err != nil {
+				return
+			}
+			atomic.AddInt32(&conns, 1)
+			go servePostgres(c)
+		}
+	}()
+
+	start := time.Now()
+
+	err = service.Init(context.Background())
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	if elapsed := time.Since(start); elapsed < startAfter {
+		t.Errorf("Init() returned after %s, before the database was reachable", elapsed)
+	}
+	if atomic.LoadInt32(&conns) == 0 {
+		t.Error("database was not connected to")
+	}
+	if service.GormDBByName("orders") == nil {
+		t.Error("gorm database not opened")
+	}
+
+	for _, shutdown := range service.shutdowns {
+		shutdown()
+	}
+}
@@ -0,0 +1,164 @@
+package micro
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+// HookPhase is a stage in the service lifecycle where hooks are run
+type HookPhase int
+
+const (
+	// PhasePreInit runs before connections to databases and external services are opened
+	PhasePreInit HookPhase = iota
+	// PhasePostInit runs after connections are opened and the gRPC server has been created
+	PhasePostInit
+	// PhaseStart runs when the service is about to start serving, before the listeners are bound
+	PhaseStart
+	// PhaseReady runs after the listeners are bound and the servers are accepting requests,
+	// before the service is reported as serving
+	PhaseReady
+	// PhasePreShutdown runs when the service is asked to stop, before the servers are drained
+	PhasePreShutdown
+	// PhasePostShutdown runs after the servers have stopped, before connections to dependencies are closed
+	PhasePostShutdown
+)
+
+var hookPhaseNames = map[HookPhase]string{
+	PhasePreInit:      "pre-init",
+	PhasePostInit:     "post-init",
+	PhaseStart:        "start",
+	PhaseReady:        "ready",
+	PhasePreShutdown:  "pre-shutdown",
+	PhasePostShutdown: "post-shutdown",
+}
+
+func (phase HookPhase) String() string {
+	if name, ok := hookPhaseNames[phase]; ok {
+		return name
+	}
+	return fmt.Sprintf("phase(%d)", int(phase))
+}
+
+// Hook is a function that is run at a given phase of the service lifecycle
+type Hook struct {
+	// Name identifies the hook in logs and errors
+	Name string
+	// Order determines when the hook runs within its phase. Hooks with lower order run first,
+	// hooks with equal order run in the order they were registered.
+	Order int
+	// Timeout is the maximum duration the hook is allowed to run. Zero means no timeout.
+	Timeout time.Duration
+	// Fn is the function to run
+	Fn func(ctx context.Context) error
+}
+
+// AddHook registers a hook to be run at the given lifecycle phase
+func (service *Service) AddHook(phase HookPhase, hook *Hook) {
+	if hook == nil || hook.Fn == nil {
+		return
+	}
+
+	service.hooksMu.Lock()
+	defer service.hooksMu.Unlock()
+
+	if service.hooks == nil {
+		service.hooks = make(map[HookPhase][]*Hook)
+	}
+	service.hooks[phase] = append(service.hooks[phase], hook)
+}
+
+// HookPhaseRan checks whether the hooks for the phase have already been run. Hooks added to the phase afterwards never run.
+func (service *Service) HookPhaseRan(phase HookPhase) bool {
+	service.hooksMu.Lock()
+	defer service.hooksMu.Unlock()
+
+	return service.hooksRun[phase]
+}
+
+// OnPreInit registers a hook that runs before connections to dependencies are opened
+func (service *Service) OnPreInit(hook *Hook) {
+	service.AddHook(PhasePreInit, hook)
+}
+
+// OnInit registers a hook that runs after the service has been initialized
+func (service *Service) OnInit(hook *Hook) {
+	service.AddHook(PhasePostInit, hook)
+}
+
+// OnStart registers a hook that runs when the service is about to start serving requests
+func (service *Service) OnStart(hook *Hook) {
+	service.AddHook(PhaseStart, hook)
+}
+
+// OnReady registers a hook that runs after the servers are accepting requests, before the service is reported as serving
+func (service *Service) OnReady(hook *Hook) {
+	service.AddHook(PhaseReady, hook)
+}
+
+// OnStop registers a hook that runs when the service is asked to stop, before the servers are drained
+func (service *Service) OnStop(hook *Hook) {
+	service.AddHook(PhasePreShutdown, hook)
+}
+
+// OnStopped registers a hook that runs after the servers have stopped
+func (service *Service) OnStopped(hook *Hook) {
+	service.AddHook(PhasePostShutdown, hook)
+}
+
+// runHooks runs all hooks registered for the phase in order, returning the combined errors
+func (service *Service) runHooks(ctx context.Context, phase HookPhase) error {
+	service.hooksMu.Lock()
+	hooks := append([]*Hook{}, service.hooks[phase]...)
+	if service.hooksRun == nil {
+		service.hooksRun = make(map[HookPhase]bool)
+	}
+	service.hooksRun[phase] = true
+	service.hooksMu.Unlock()
+
+	sort.SliceStable(hooks, func(i, j int) bool {
+		return hooks[i].Order < hooks[j].Order
+	})
+
+	var errs error
+
+	for _, hook := range hooks {
+		err := runHook(ctx, hook)
+		if err != nil {
+			errs = multierr.Append(errs, errors.Wrapf(err, "%s hook %q failed", phase, hook.Name))
+		}
+	}
+
+	return errs
+}
+
+func runHook(ctx context.Context, hook *Hook) error {
+	if hook.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, hook.Timeout)
+		defer cancel()
+	}
+
+	errChan := make(chan error, 1)
+
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				errChan <- fmt.Errorf("panic: %v", err)
+			}
+		}()
+		errChan <- hook.Fn(ctx)
+	}()
+
+	select {
+	case err := <-errChan:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
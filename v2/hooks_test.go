@@ -0,0 +1,136 @@
+package micro
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gidyon/micro/v2/internal/testutil"
+	"google.golang.org/grpc/connectivity"
+)
+
+func TestRunHooks(t *testing.T) {
+	var (
+		service = &Service{}
+		order   = make([]string, 0)
+		record  = func(name string, err error) func(context.Context) error {
+			return func(context.Context) error {
+				order = append(order, name)
+				return err
+			}
+		}
+	)
+
+	service.OnInit(&Hook{Name: "third", Order: 10, Fn: record("third", nil)})
+	service.OnInit(&Hook{Name: "first", Order: -1, Fn: record("first", nil)})
+	service.OnInit(&Hook{Name: "second", Fn: record("second", errors.New("failed"))})
+	service.OnInit(&Hook{Name: "fourth", Order: 10, Fn: record("fourth", nil)})
+	service.OnInit(&Hook{Name: "slow", Order: 20, Timeout: 10 * time.Millisecond, Fn: func(ctx context.Context) error {
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}})
+	service.OnReady(&Hook{Name: "other phase", Fn: record("other phase", nil)})
+
+	err := service.runHooks(context.Background(), PhasePostInit)
+
+	want := []string{"first", "second", "third", "fourth"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("runHooks() order = %v, want %v", order, want)
+	}
+
+	wantErr := `post-init hook "second" failed: failed; post-init hook "slow" failed: context deadline exceeded`
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("runHooks() error = %v, want %v", err, wantErr)
+	}
+}
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+
+	cfg, err := testutil.NewBuilder(t).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := NewService(context.Background(), cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return service
+}
+
+func TestRunFailingStartHook(t *testing.T) {
+	service := newTestService(t)
+
+	stopped := false
+	service.OnStart(&Hook{Name: "failing", Fn: func(context.Context) error { return errors.New("failed") }})
+	service.OnStopped(&Hook{Name: "stopped", Fn: func(context.Context) error {
+		stopped = true
+		return nil
+	}})
+
+	err := service.Run(context.Background())
+	if err == nil {
+		t.Fatal("Run() expected error from start hook")
+	}
+
+	switch {
+	case !stopped:
+		t.Error("shutdown hooks not run after start hook failed")
+	case service.ClientConn().GetState() != connectivity.Shutdown:
+		t.Error("gateway client connection not closed after start hook failed")
+	case service.State() != StateStopped:
+		t.Errorf("State() = %s, want %s", service.State(), StateStopped)
+	}
+}
+
+func TestSetAutoMigrator(t *testing.T) {
+	service := newTestService(t)
+
+	migrations := 0
+	migrate := func() error {
+		migrations++
+		if service.State() != StateMigrating {
+			t.Errorf("State() = %s while migrating, want %s", service.State(), StateMigrating)
+		}
+		return nil
+	}
+
+	err := service.SetAutoMigrator(migrate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = service.SetAutoMigrator(migrate); err == nil {
+		t.Error("SetAutoMigrator() expected error when already set")
+	}
+	if migrations != 0 {
+		t.Fatalf("migration ran %d times before ready hooks", migrations)
+	}
+
+	err = service.runHooks(context.Background(), PhaseReady)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrations != 1 {
+		t.Fatalf("migration ran %d times with ready hooks, want 1", migrations)
+	}
+
+	// Migrations set once the service is ready run immediately
+	service = newTestService(t)
+	err = service.runHooks(context.Background(), PhaseReady)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = service.SetAutoMigrator(migrate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrations != 2 {
+		t.Errorf("migration ran %d times when set after ready hooks, want 2", migrations)
+	}
+}
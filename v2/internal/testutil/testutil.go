@@ -0,0 +1,29 @@
+// Package testutil contains helpers shared by the tests of the service and its packages
+package testutil
+
+import (
+	"net"
+	"testing"
+
+	"github.com/gidyon/micro/v2/pkg/config"
+)
+
+// FreePort returns a tcp port that is free on localhost
+func FreePort(t testing.TB) int {
+	t.Helper()
+
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+
+	return lis.Addr().(*net.TCPAddr).Port
+}
+
+// NewBuilder returns the config builder of an insecure test service listening on free ports
+func NewBuilder(t testing.TB) *config.Builder {
+	t.Helper()
+
+	return config.NewBuilder("test").Insecure().HTTPort(FreePort(t)).GRPCPort(FreePort(t))
+}
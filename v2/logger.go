@@ -3,34 +3,55 @@ package micro
 import (
 	"fmt"
 	"os"
+	"sync/atomic"
 
 	"google.golang.org/grpc/grpclog"
 
+	"github.com/gidyon/micro/v2/pkg/config"
 	"github.com/rs/zerolog"
 )
 
 type logger struct {
-	log zerolog.Logger
+	log   zerolog.Logger
+	level int32
 }
 
-// NewLogger creates a grpc logger using zerolog
+// NewLogger creates a grpc logger using zerolog. The level can be changed later with SetLevel.
 func NewLogger(serviceName string, level zerolog.Level) grpclog.LoggerV2 {
 	log := zerolog.New(os.Stdout).With().
 		Timestamp().
 		Caller().
 		Str("protocol", "grpc").
 		Str("service_name", serviceName).
-		Logger().
-		Level(level)
-	return &logger{log: log}
+		Logger()
+	return &logger{log: log, level: int32(level)}
+}
+
+// logLevel returns the log level set in cfg, defaulting to trace
+func logLevel(cfg *config.Config) zerolog.Level {
+	if !cfg.LogLevelSet() {
+		return zerolog.TraceLevel
+	}
+	return zerolog.Level(cfg.LogLevel())
+}
+
+// SetLevel changes the minimum level of logged messages
+func (l *logger) SetLevel(level zerolog.Level) {
+	atomic.StoreInt32(&l.level, int32(level))
+}
+
+// leveled returns the logger with the current level
+func (l *logger) leveled() *zerolog.Logger {
+	log := l.log.Level(zerolog.Level(atomic.LoadInt32(&l.level)))
+	return &log
 }
 
 func (l *logger) Info(args ...interface{}) {
-	l.log.Info().Msg(fmt.Sprint(args...))
+	l.leveled().Info().Msg(fmt.Sprint(args...))
 }
 
 func (l *logger) Infof(format string, args ...interface{}) {
-	l.log.Info().Msg(fmt.Sprintf(format, args...))
+	l.leveled().Info().Msg(fmt.Sprintf(format, args...))
 }
 
 func (l *logger) Infoln(args ...interface{}) {
@@ -38,11 +59,11 @@ func (l *logger) Infoln(args ...interface{}) {
 }
 
 func (l *logger) Warning(args ...interface{}) {
-	l.log.Warn().Msg(fmt.Sprint(args...))
+	l.leveled().Warn().Msg(fmt.Sprint(args...))
 }
 
 func (l *logger) Warningf(format string, args ...interface{}) {
-	l.log.Warn().Msg(fmt.Sprintf(format, args...))
+	l.leveled().Warn().Msg(fmt.Sprintf(format, args...))
 }
 
 func (l *logger) Warningln(args ...interface{}) {
@@ -50,22 +71,22 @@ func (l *logger) Warningln(args ...interface{}) {
 }
 
 func (l *logger) Error(args ...interface{}) {
-	l.log.Error().Msg(fmt.Sprint(args...))
+	l.leveled().Error().Msg(fmt.Sprint(args...))
 }
 
 func (l *logger) Errorf(format string, args ...interface{}) {
-	l.log.Error().Msg(fmt.Sprintf(format, args...))
+	l.leveled().Error().Msg(fmt.Sprintf(format, args...))
 }
 
 func (l *logger) Errorln(args ...interface{}) {
 	l.Error(args...)
 }
 func (l *logger) Fatal(args ...interface{}) {
-	l.log.Fatal().Msg(fmt.Sprint(args...))
+	l.leveled().Fatal().Msg(fmt.Sprint(args...))
 }
 
 func (l *logger) Fatalf(format string, args ...interface{}) {
-	l.log.Fatal().Msg(fmt.Sprintf(format, args...))
+	l.leveled().Fatal().Msg(fmt.Sprintf(format, args...))
 }
 
 func (l *logger) Fatalln(args ...interface{}) {
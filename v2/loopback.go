@@ -0,0 +1,89 @@
+package micro
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/peer"
+)
+
+// loopbackListener is an in-memory listener the gateway uses to reach the gRPC server when client certificates
+// are verified. Connections on it never present the service certificate, the gateway instead forwards the
+// identity of the REST caller in metadata, which the gRPC server trusts only from this listener.
+type loopbackListener struct {
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+type loopbackAddr struct{}
+
+func (loopbackAddr) Network() string { return "loopback" }
+
+func (loopbackAddr) String() string { return "gateway" }
+
+type loopbackConn struct {
+	net.Conn
+}
+
+func (loopbackConn) LocalAddr() net.Addr { return loopbackAddr{} }
+
+func (loopbackConn) RemoteAddr() net.Addr { return loopbackAddr{} }
+
+func newLoopbackListener() *loopbackListener {
+	return &loopbackListener{
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+}
+
+// Accept waits for the next connection dialed by the gateway
+func (l *loopbackListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close stops accepting connections
+func (l *loopbackListener) Close() error {
+	l.once.Do(func() {
+		close(l.done)
+	})
+	return nil
+}
+
+// Addr returns the address of the listener
+func (l *loopbackListener) Addr() net.Addr {
+	return loopbackAddr{}
+}
+
+// Dial connects to the listener, it is used as the gateway context dialer
+func (l *loopbackListener) Dial(ctx context.Context, _ string) (net.Conn, error) {
+	server, client := net.Pipe()
+
+	select {
+	case l.conns <- loopbackConn{Conn: server}:
+		return loopbackConn{Conn: client}, nil
+	case <-l.done:
+	case <-ctx.Done():
+	}
+
+	server.Close()
+	client.Close()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("gateway loopback listener closed")
+}
+
+// isLoopbackPeer checks whether a gRPC request was forwarded by the gateway over the loopback listener
+func isLoopbackPeer(p *peer.Peer) bool {
+	_, ok := p.Addr.(loopbackAddr)
+	return ok
+}
@@ -2,6 +2,7 @@ package micro
 
 import (
 	"context"
+	"crypto/tls"
 	"database/sql"
 	"strings"
 	"sync"
@@ -11,12 +12,14 @@ import (
 	"github.com/gidyon/micro/v2/pkg/config"
 	"github.com/gidyon/micro/v2/pkg/conn"
 	http_middleware "github.com/gidyon/micro/v2/pkg/middleware/http"
+	"github.com/gidyon/micro/v2/utils/tlsutil"
 	redis "github.com/go-redis/redis/v8"
 	"github.com/pkg/errors"
-	"github.com/rs/zerolog"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/grpclog"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"gorm.io/gorm"
 
 	"net/http"
@@ -25,6 +28,9 @@ import (
 	"google.golang.org/grpc"
 )
 
+// defaultShutdownTimeout is the maximum time given to servers to finish pending requests on shutdown
+const defaultShutdownTimeout = 30 * time.Second
+
 // Service contains API clients, connections and options for bootstrapping a micro-service.
 type Service struct {
 	cfg                      *config.Config
@@ -38,9 +44,12 @@ type Service struct {
 	runtimeMuxEndpoint       string
 	httpMiddlewares          []http_middleware.Middleware
 	httpMux                  *http.ServeMux
+	adminMux                 *http.ServeMux
 	runtimeMux               *runtime.ServeMux
 	clientConn               *grpc.ClientConn
+	loopback                 *loopbackListener
 	gRPCServer               *grpc.Server
+	healthServer             *health.Server
 	externalServicesConn     map[string]*grpc.ClientConn
 	serveMuxOptions          []runtime.ServeMuxOption
 	serverOptions            []grpc.ServerOption
@@ -51,30 +60,38 @@ type Service struct {
 	unaryClientInterceptors  []grpc.UnaryClientInterceptor
 	streamClientInterceptors []grpc.StreamClientInterceptor
 	shutdowns                []func() error
+	certReloader             *tlsutil.CertReloader
+	tlsConfig                *tls.Config
+	hooks                    map[HookPhase][]*Hook
+	hooksRun                 map[HookPhase]bool
+	hooksMu                  sync.Mutex
+	autoMigrator             bool
 	// timeouts
 	httpServerReadTimeout  int
 	httpServerWriteTimeout int
+	shutdownDrainPeriod    time.Duration
+	shutdownTimeout        time.Duration
+	state                  int32
+	inflightGRPC           int64
 	initOnceFn             *sync.Once
+	initErr                error
 	runOnceFn              *sync.Once
 	nowFunc                func() time.Time
 }
 
 // NewService create a micro-service utility store by parsing data from config. Pass nil logger to use default logger
+// whose level follows logLevel in config, including when config is reloaded.
 func NewService(_ context.Context, cfg *config.Config, grpcLogger grpclog.LoggerV2) (*Service, error) {
 	if cfg == nil {
 		return nil, errors.New("nil config not allowed")
 	}
 
-	if cfg.StartupSleepSeconds() > 0 {
-		time.Sleep(time.Duration(cfg.StartupSleepSeconds()) * time.Second)
-	}
-
 	var logger grpclog.LoggerV2
 
 	if grpcLogger != nil {
 		logger = grpcLogger
 	} else {
-		logger = NewLogger(cfg.ServiceName(), zerolog.TraceLevel)
+		logger = NewLogger(cfg.ServiceName(), logLevel(cfg))
 	}
 
 	svc := &Service{
@@ -92,6 +109,7 @@ func NewService(_ context.Context, cfg *config.Config, grpcLogger grpclog.Logger
 		runtimeMux:               runtime.NewServeMux(),
 		clientConn:               &grpc.ClientConn{},
 		gRPCServer:               &grpc.Server{},
+		healthServer:             health.NewServer(),
 		externalServicesConn:     map[string]*grpc.ClientConn{},
 		serveMuxOptions:          make([]runtime.ServeMuxOption, 0),
 		serverOptions:            make([]grpc.ServerOption, 0),
@@ -102,8 +120,11 @@ func NewService(_ context.Context, cfg *config.Config, grpcLogger grpclog.Logger
 		unaryClientInterceptors:  make([]grpc.UnaryClientInterceptor, 0),
 		streamClientInterceptors: make([]grpc.StreamClientInterceptor, 0),
 		shutdowns:                make([]func() error, 0),
+		hooks:                    make(map[HookPhase][]*Hook),
 		httpServerReadTimeout:    0,
 		httpServerWriteTimeout:   0,
+		shutdownDrainPeriod:      time.Duration(cfg.ShutdownDrainSeconds()) * time.Second,
+		shutdownTimeout:          defaultShutdownTimeout,
 		initOnceFn:               &sync.Once{},
 		runOnceFn:                &sync.Once{},
 		nowFunc: func() time.Time {
@@ -111,6 +132,11 @@ func NewService(_ context.Context, cfg *config.Config, grpcLogger grpclog.Logger
 		},
 	}
 
+	// The service is not serving until the servers start
+	svc.healthServer.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+
+	cfg.Subscribe(svc.applyConfigChanges)
+
 	return svc, nil
 }
 
@@ -244,6 +270,20 @@ func (service *Service) GRPCServer() *grpc.Server {
 	return service.gRPCServer
 }
 
+// HealthServer returns the gRPC health checking service registered on the gRPC server.
+// It reports NOT_SERVING until the servers start and after the service starts shutting down.
+func (service *Service) HealthServer() *health.Server {
+	return service.healthServer
+}
+
+// setServingStatus sets the serving status of the service and every service registered on the gRPC server
+func (service *Service) setServingStatus(status healthpb.HealthCheckResponse_ServingStatus) {
+	service.healthServer.SetServingStatus("", status)
+	for name := range service.gRPCServer.GetServiceInfo() {
+		service.healthServer.SetServingStatus(name, status)
+	}
+}
+
 // GormDB returns the first gorm db with name "mysql"
 func (service *Service) GormDB() *gorm.DB {
 	return service.gormDBs["mysql"]
@@ -311,11 +351,11 @@ func (service *Service) DialExternalService(
 	dopts := append([]grpc.DialOption{}, dialOptions...)
 
 	if !serviceInfo.Insecure() {
-		creds, err := credentials.NewClientTLSFromFile(serviceInfo.TLSCertFile(), serviceInfo.ServerName())
+		tlsConfig, err := service.clientTLSConfig(serviceInfo.TLSCertFile(), serviceInfo.ServerName())
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to create tls config for %s service", serviceInfo.Name())
 		}
-		dopts = append(dopts, grpc.WithTransportCredentials(creds))
+		dopts = append(dopts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
 	} else {
 		dopts = append(dopts, grpc.WithTransportCredentials(insecure.NewCredentials()))
 	}
@@ -395,6 +435,22 @@ func (service *Service) SetHTTPServerWriteTimout(sec int) {
 	service.httpServerWriteTimeout = sec
 }
 
+// SetShutdownDrainPeriod sets the period to wait after readiness starts failing and before the servers are stopped.
+// This gives load balancers time to stop routing new traffic to the service. It overrides shutdownDrainSeconds in config.
+func (service *Service) SetShutdownDrainPeriod(sec int) {
+	service.shutdownDrainPeriod = time.Duration(sec) * time.Second
+}
+
+// SetShutdownTimeout sets the maximum period given to the servers to finish in-flight requests on shutdown
+func (service *Service) SetShutdownTimeout(sec int) {
+	service.shutdownTimeout = time.Duration(sec) * time.Second
+}
+
+// ShuttingDown checks whether the service has received a stop signal and is draining requests
+func (service *Service) ShuttingDown() bool {
+	return service.State() >= StateDraining
+}
+
 // SetNowFunc sets the function to be used when creating a new timestamp
 func (service *Service) SetNowFunc(f func() time.Time) {
 	service.nowFunc = f
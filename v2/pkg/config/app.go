@@ -0,0 +1,347 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"gopkg.in/yaml.v2"
+)
+
+// appKey is the yaml key of the application specific section
+const appKey = "app"
+
+// DecodeApp decodes the app section of the config into v which must be a pointer to a struct.
+//
+// Fields are named using yaml tags. Values are applied with precedence default < file < env < flags:
+//   - default:"value" tags set fields that are not set in the file
+//   - a string field named x is read from the file at path xFile when xFile is set in the file,
+//     or, for fields tagged secret:"true", from the file named by its value when the value has the file://
+//     prefix. Values of such fields with the secret:// prefix are resolved by the registered SecretProvider
+//   - string values of integer, number and boolean fields, such as those substituted for ${VAR} variables,
+//     are converted the same way yaml resolves plain scalars
+//   - environment variables and --set flags override fields as described in EnvPrefix, e.g
+//     MICRO_APP_FEATURES_CHECKOUT or --set app.features.checkout=true
+//
+// Afterwards fields are validated using validate tags with comma separated rules:
+// required, min=n, max=n and oneof=a b c. For strings, slices and maps min and max apply to the length.
+func (cfg *Config) DecodeApp(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.New("app config must be a non-nil pointer to a struct")
+	}
+
+	err := setDefaults(rv.Elem(), appKey)
+	if err != nil {
+		return err
+	}
+
+	current := cfg.snapshot()
+
+	// Secrets are read into a copy so that the config is left unchanged
+	app := make(map[interface{}]interface{}, len(current.App))
+	for key, val := range current.App {
+		app[key] = copyYAMLValue(val)
+	}
+
+	err = readAppSecrets(rv.Elem().Type(), app, appKey)
+	if err != nil {
+		return err
+	}
+
+	typeAppValues(rv.Elem().Type(), app)
+
+	bs, err := yaml.Marshal(app)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal app config")
+	}
+
+	err = yaml.UnmarshalStrict(bs, v)
+	if err != nil {
+		return errors.Wrap(err, "failed to decode app config")
+	}
+
+	fields := make([]*overrideField, 0)
+	walkOverrideFields(rv.Elem().Type(), []string{appKey}, func() reflect.Value { return rv.Elem() }, &fields)
+
+	err = applyEnvOverrides(fields, current.environ)
+	if err != nil {
+		return err
+	}
+
+	err = applyFlagOverrides(fields, current.appSets)
+	if err != nil {
+		return err
+	}
+
+	return errors.Wrap(validateStruct(rv.Elem(), appKey), "app config validation error")
+}
+
+// setDefaults sets zero value fields using their default tag
+func setDefaults(v reflect.Value, path string) error {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field, fv := t.Field(i), v.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		fieldPath := path + "." + yamlName(field)
+
+		if fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct {
+			err := setDefaults(fv, fieldPath)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		def, ok := field.Tag.Lookup("default")
+		if !ok || !fv.IsZero() {
+			continue
+		}
+
+		err := setFieldValue(fv, def)
+		if err != nil {
+			return errors.Wrapf(err, "invalid default for %s", fieldPath)
+		}
+	}
+
+	return nil
+}
+
+// readAppSecrets replaces keys named xFile with the content of the file for string fields named x.
+// Values of string fields tagged secret:"true" with the file:// or secret:// prefix are replaced with the secret
+// they reference.
+func readAppSecrets(t reflect.Type, m map[interface{}]interface{}, path string) error {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	fields := make(map[string]reflect.StructField, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).PkgPath == "" {
+			fields[yamlName(t.Field(i))] = t.Field(i)
+		}
+	}
+
+	for key, val := range m {
+		name, _ := key.(string)
+
+		if field, ok := fields[name]; ok {
+			switch val := val.(type) {
+			case map[interface{}]interface{}:
+				err := readAppSecrets(field.Type, val, path+"."+name)
+				if err != nil {
+					return err
+				}
+			case string:
+				if field.Type.Kind() != reflect.String || field.Tag.Get("secret") != "true" {
+					continue
+				}
+				secret, ok, err := readSecretValue(val)
+				if err != nil {
+					return errors.Wrapf(err, "failed to read secret %s.%s", path, name)
+				}
+				if ok {
+					m[key] = secret
+				}
+			}
+			continue
+		}
+
+		target := strings.TrimSuffix(name, "File")
+		field, ok := fields[target]
+		if target == name || !ok || field.Type.Kind() != reflect.String {
+			continue
+		}
+
+		filename, _ := val.(string)
+		if strings.TrimSpace(filename) == "" {
+			delete(m, key)
+			continue
+		}
+
+		secret, err := readSecretFile(strings.TrimPrefix(filename, FilePrefix))
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s.%s from file", path, target)
+		}
+
+		delete(m, key)
+		m[target] = secret
+	}
+
+	return nil
+}
+
+// typeAppValues converts string values of integer, number and boolean fields of the struct type t,
+// which stay strings when variables are interpolated since the app section has no schema
+func typeAppValues(t reflect.Type, m map[interface{}]interface{}) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field, key := t.Field(i), yamlName(t.Field(i))
+		if field.PkgPath != "" || key == "-" {
+			continue
+		}
+
+		switch val := m[key].(type) {
+		case map[interface{}]interface{}:
+			typeAppValues(field.Type, val)
+		case string:
+			m[key] = scalarValue(val, schemaFor(field.Type, ""))
+		}
+	}
+}
+
+// copyYAMLValue deep copies maps and lists decoded from yaml, other values are immutable
+func copyYAMLValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[interface{}]interface{}, len(val))
+		for key, elem := range val {
+			m[key] = copyYAMLValue(elem)
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, 0, len(val))
+		for _, elem := range val {
+			list = append(list, copyYAMLValue(elem))
+		}
+		return list
+	}
+	return v
+}
+
+// validateStruct validates fields using their validate tag, returning all failures
+func validateStruct(v reflect.Value, path string) error {
+	var errs error
+
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field, fv := t.Field(i), v.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		fieldPath := path + "." + yamlName(field)
+
+		if rules := field.Tag.Get("validate"); rules != "" {
+			for _, rule := range strings.Split(rules, ",") {
+				err := validateRule(fv, strings.TrimSpace(rule))
+				if err != nil {
+					errs = multierr.Append(errs, fmt.Errorf("%s: %v", fieldPath, err))
+				}
+			}
+		}
+
+		errs = multierr.Append(errs, validateNested(fv, fieldPath))
+	}
+
+	return errs
+}
+
+func validateNested(v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return validateNested(v.Elem(), path)
+		}
+	case reflect.Struct:
+		return validateStruct(v, path)
+	case reflect.Slice:
+		var errs error
+		for i := 0; i < v.Len(); i++ {
+			errs = multierr.Append(errs, validateNested(v.Index(i), fmt.Sprintf("%s[%d]", path, i)))
+		}
+		return errs
+	}
+	return nil
+}
+
+func validateRule(v reflect.Value, rule string) error {
+	name, arg := rule, ""
+	if i := strings.Index(rule, "="); i >= 0 {
+		name, arg = rule[:i], rule[i+1:]
+	}
+
+	switch name {
+	case "":
+		return nil
+	case "required":
+		if v.IsZero() {
+			return errors.New("value is required")
+		}
+	case "min", "max":
+		limit, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return fmt.Errorf("invalid %s rule %q", name, rule)
+		}
+		val, isLen, ok := numericValue(v)
+		if !ok {
+			return fmt.Errorf("%s rule not supported for %s", name, v.Type())
+		}
+		desc := "value"
+		if isLen {
+			desc = "length"
+		}
+		if name == "min" && val < limit {
+			return fmt.Errorf("%s must be at least %s", desc, arg)
+		}
+		if name == "max" && val > limit {
+			return fmt.Errorf("%s must be at most %s", desc, arg)
+		}
+	case "oneof":
+		val := fmt.Sprint(v.Interface())
+		for _, allowed := range strings.Fields(arg) {
+			if val == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("value %q must be one of [%s]", val, arg)
+	default:
+		return fmt.Errorf("unknown validation rule %q", rule)
+	}
+
+	return nil
+}
+
+// numericValue returns the value of numbers or the length of strings, slices and maps
+func numericValue(v reflect.Value) (val float64, isLen, ok bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), false, true
+	case reflect.String, reflect.Slice, reflect.Map:
+		return float64(v.Len()), true, true
+	}
+	return 0, false, false
+}
+
+// yamlName returns the yaml key of a struct field, defaulting to the lowercased field name like yaml.v2
+func yamlName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+	if name == "" {
+		name = strings.ToLower(field.Name)
+	}
+	return name
+}
@@ -0,0 +1,233 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+type testAppConfig struct {
+	PaymentsURL string        `yaml:"paymentsURL" validate:"required"`
+	APIKey      string        `yaml:"apiKey" validate:"required"`
+	Timeout     time.Duration `yaml:"timeout" default:"5s"`
+	Mode        string        `yaml:"mode" default:"live" validate:"oneof=live sandbox"`
+	Limits      struct {
+		MaxItems int `yaml:"maxItems" default:"10" validate:"min=1,max=100"`
+	} `yaml:"limits"`
+	Features struct {
+		Checkout bool `yaml:"checkout"`
+	} `yaml:"features"`
+}
+
+func newAppTestConfig(t *testing.T, content string) *Config {
+	t.Helper()
+
+	cfg := newConfig()
+	err := yaml.UnmarshalStrict([]byte(content), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Config{config: *cfg}
+}
+
+func TestDecodeApp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyFile := filepath.Join(dir, "api-key")
+	err = ioutil.WriteFile(keyFile, []byte("s3cret\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := newAppTestConfig(t, `
+app:
+  paymentsURL: https://payments.local
+  apiKeyFile: `+keyFile+`
+  limits:
+    maxItems: 50
+`)
+
+	err = cfg.config.setConfigFromEnv([]string{"MICRO_APP_FEATURES_CHECKOUT=true", "MICRO_APP_LIMITS_MAX_ITEMS=20"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cfg.config.setConfigFromFlags([]string{"app.limits.maxItems=30"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &testAppConfig{}
+	err = cfg.DecodeApp(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	switch {
+	case app.PaymentsURL != "https://payments.local":
+		t.Errorf("paymentsURL = %q", app.PaymentsURL)
+	case app.APIKey != "s3cret":
+		t.Errorf("apiKey = %q, want value from file", app.APIKey)
+	case app.Timeout != 5*time.Second || app.Mode != "live":
+		t.Errorf("defaults not applied: timeout = %s, mode = %q", app.Timeout, app.Mode)
+	case !app.Features.Checkout:
+		t.Error("features.checkout not set from env")
+	case app.Limits.MaxItems != 30:
+		t.Errorf("limits.maxItems = %d, want 30 from flag", app.Limits.MaxItems)
+	}
+}
+
+func TestDecodeAppSecretFields(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyFile := filepath.Join(dir, "api-key")
+	err = ioutil.WriteFile(keyFile, []byte("s3cret\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := newAppTestConfig(t, "app:\n  key: file://"+keyFile+"\n  url: file://"+keyFile+"\n")
+
+	app := &struct {
+		Key string `yaml:"key" secret:"true"`
+		URL string `yaml:"url"`
+	}{}
+	err = cfg.DecodeApp(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if app.Key != "s3cret" {
+		t.Errorf("key = %q, want value from file", app.Key)
+	}
+	if app.URL != "file://"+keyFile {
+		t.Errorf("url = %q, want value unchanged since it is not a secret field", app.URL)
+	}
+}
+
+func TestDecodeAppInterpolatedValues(t *testing.T) {
+	env := map[string]string{"CONFIG_TEST_API_KEY": "12345", "CONFIG_TEST_MAX_ITEMS": "20", "CONFIG_TEST_CHECKOUT": "yes"}
+	for name, val := range env {
+		os.Setenv(name, val)
+		defer os.Unsetenv(name)
+	}
+
+	content := `
+serviceName: orders
+httpPort: 8080
+security:
+  insecure: true
+app:
+  paymentsURL: https://payments.local
+  apiKey: ${CONFIG_TEST_API_KEY}
+  limits:
+    maxItems: ${CONFIG_TEST_MAX_ITEMS}
+  features:
+    checkout: ${CONFIG_TEST_CHECKOUT}
+`
+
+	cfg, err := Load(&Options{File: "config.yml", Reader: strings.NewReader(content)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &testAppConfig{}
+	err = cfg.DecodeApp(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	switch {
+	case app.APIKey != "12345":
+		t.Errorf("apiKey = %q, want string 12345", app.APIKey)
+	case app.Limits.MaxItems != 20:
+		t.Errorf("limits.maxItems = %d, want 20", app.Limits.MaxItems)
+	case !app.Features.Checkout:
+		t.Error("features.checkout = false, want true")
+	}
+}
+
+func TestDecodeAppLeavesConfigUnchanged(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tokenFile := filepath.Join(dir, "token")
+	err = ioutil.WriteFile(tokenFile, []byte("s3cret\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := newAppTestConfig(t, "app:\n  payments:\n    tokenFile: "+tokenFile+"\n")
+
+	app := &struct {
+		Payments struct {
+			Token string `yaml:"token"`
+		} `yaml:"payments"`
+	}{}
+	err = cfg.DecodeApp(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if app.Payments.Token != "s3cret" {
+		t.Errorf("payments.token = %q, want value from file", app.Payments.Token)
+	}
+
+	// Secrets read from nested sections must not be written back to the config
+	payments, _ := cfg.config.App["payments"].(map[interface{}]interface{})
+	if _, ok := payments["tokenFile"]; !ok || payments["token"] != nil {
+		t.Errorf("app.payments changed by DecodeApp: %v", payments)
+	}
+}
+
+func TestDecodeAppErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr []string
+	}{
+		{
+			name:    "unknown field",
+			content: "app:\n  paymentsURL: x\n  apiKey: y\n  unknown: z\n",
+			wantErr: []string{"field unknown not found"},
+		},
+		{
+			name:    "validation",
+			content: "app:\n  mode: test\n  limits:\n    maxItems: 200\n",
+			wantErr: []string{
+				"app.paymentsURL: value is required",
+				"app.apiKey: value is required",
+				`app.mode: value "test" must be one of [live sandbox]`,
+				"app.limits.maxItems: value must be at most 100",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newAppTestConfig(t, tt.content).DecodeApp(&testAppConfig{})
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Builder creates config in code e.g for tests and embedded tools. Config built is validated like config
+// read from files, environment variables and command line flags are not read.
+type Builder struct {
+	cfg *config
+	err error
+}
+
+// DatabaseSpec describes a database added with Builder.Database
+type DatabaseSpec struct {
+	// Name is the unique name of the database, used to look it up
+	Name string
+	// Type is either SQLDBType or RedisDBType
+	Type          string
+	Dialect       string
+	ORM           string
+	Address       string
+	User          string
+	Password      string
+	Schema        string
+	Required      bool
+	UseRediSearch bool
+	// Postgres connection options
+	SSLMode    string
+	SearchPath string
+	TimeZone   string
+}
+
+// ServiceSpec describes an external service added with Builder.ExternalService
+type ServiceSpec struct {
+	Name        string
+	Address     string
+	TLSCertFile string
+	ServerName  string
+	Required    bool
+	Insecure    bool
+	K8Service   bool
+}
+
+// NewBuilder creates a config builder for a service. The service uses tls unless Insecure is called.
+func NewBuilder(serviceName string) *Builder {
+	cfg := newConfig()
+	cfg.ServiceName = serviceName
+	return &Builder{cfg: cfg}
+}
+
+// ServiceType sets the kubernetes service type
+func (b *Builder) ServiceType(serviceType string) *Builder {
+	b.cfg.ServiceType = serviceType
+	return b
+}
+
+// HTTPort sets the port serving REST and, when tls or h2c is enabled, gRPC
+func (b *Builder) HTTPort(port int) *Builder {
+	b.cfg.HTTPort = port
+	return b
+}
+
+// GRPCPort sets the port serving gRPC when tls and h2c are disabled
+func (b *Builder) GRPCPort(port int) *Builder {
+	b.cfg.GRPCPort = port
+	return b
+}
+
+// AdminPort sets the port serving health, metrics and admin endpoints
+func (b *Builder) AdminPort(port int) *Builder {
+	b.cfg.AdminPort = port
+	return b
+}
+
+// LogLevel sets the log level
+func (b *Builder) LogLevel(level int) *Builder {
+	b.cfg.LogLevel = level
+	return b
+}
+
+// Insecure serves the service without tls
+func (b *Builder) Insecure() *Builder {
+	b.cfg.Security.Insecure = true
+	return b
+}
+
+// TLS serves the service over tls using the given certificate and key files
+func (b *Builder) TLS(certFile, keyFile, serverName string) *Builder {
+	b.cfg.Security.Insecure = false
+	b.cfg.Security.TLSCertFile = certFile
+	b.cfg.Security.TLSKeyFile = keyFile
+	b.cfg.Security.ServerName = serverName
+	return b
+}
+
+// H2C serves gRPC and REST on the same cleartext port using HTTP/2 without tls
+func (b *Builder) H2C() *Builder {
+	b.cfg.HttpOtions.H2CEnabled = true
+	return b
+}
+
+// CORS allows cross origin requests
+func (b *Builder) CORS() *Builder {
+	b.cfg.HttpOtions.CorsEnabled = true
+	return b
+}
+
+// Database adds a database
+func (b *Builder) Database(db *DatabaseSpec) *Builder {
+	if db == nil {
+		b.setErr(errors.New("nil database not allowed"))
+		return b
+	}
+
+	b.cfg.Databases = append(b.cfg.Databases, &databaseOptions{
+		Required: db.Required,
+		Type:     db.Type,
+		Address:  db.Address,
+		User:     db.User,
+		Schema:   db.Schema,
+		Password: db.Password,
+		Metadata: &dbMetadata{
+			Name:          db.Name,
+			Dialect:       db.Dialect,
+			Orm:           db.ORM,
+			UseRediSearch: db.UseRediSearch,
+			SSLMode:       db.SSLMode,
+			SearchPath:    db.SearchPath,
+			TimeZone:      db.TimeZone,
+		},
+	})
+
+	return b
+}
+
+// ExternalService adds an external service
+func (b *Builder) ExternalService(srv *ServiceSpec) *Builder {
+	if srv == nil {
+		b.setErr(errors.New("nil service not allowed"))
+		return b
+	}
+
+	b.cfg.ExternalServices = append(b.cfg.ExternalServices, &externalServiceOptions{
+		Name:        srv.Name,
+		Required:    srv.Required,
+		K8Service:   srv.K8Service,
+		Address:     srv.Address,
+		TLSCertFile: srv.TLSCertFile,
+		ServerName:  srv.ServerName,
+		Insecure:    srv.Insecure,
+	})
+
+	return b
+}
+
+// App sets the application specific section decoded by Config.DecodeApp
+func (b *Builder) App(app map[string]interface{}) *Builder {
+	b.cfg.App = app
+	return b
+}
+
+// Set sets a config value using its yaml path like the --set flag, e.g databases.orders.poolSettings.maxOpenConns=10.
+// Use it for fields without a builder method.
+func (b *Builder) Set(path, value string) *Builder {
+	err := b.cfg.setConfigFromFlags([]string{fmt.Sprintf("%s=%s", path, value)})
+	if err != nil {
+		b.setErr(err)
+	}
+	return b
+}
+
+func (b *Builder) setErr(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// Build validates the config and returns it. The builder can be changed and built again afterwards.
+func (b *Builder) Build() (*Config, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	// Copy config so that later changes to the builder do not affect it
+	bs, err := yaml.Marshal(b.cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal config")
+	}
+
+	cfg := newConfig()
+
+	err = yaml.UnmarshalStrict(bs, cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to copy config")
+	}
+	cfg.appSets = append([]string{}, b.cfg.appSets...)
+
+	err = cfg.updateConfigSecrets()
+	if err != nil {
+		return nil, fmt.Errorf("failed to set config from secrets file: %w", err)
+	}
+
+	err = errors.Wrap(cfg.validate(), "validation error")
+	if err != nil {
+		return nil, err
+	}
+
+	return newLiveConfig(cfg), nil
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	b := NewBuilder("orders").
+		HTTPort(8080).
+		GRPCPort(8081).
+		Insecure().
+		Database(&DatabaseSpec{
+			Name:     "orders",
+			Type:     SQLDBType,
+			Dialect:  "postgres",
+			Address:  "localhost:5432",
+			User:     "postgres",
+			Password: "s3cret",
+			Schema:   "orders",
+			Required: true,
+		}).
+		ExternalService(&ServiceSpec{Name: "payments", Address: "localhost:9000", Insecure: true, Required: true}).
+		Set("databases.orders.poolSettings.maxOpenConns", "10").
+		App(map[string]interface{}{"paymentsURL": "https://payments.local", "apiKey": "key"})
+
+	cfg, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := cfg.SQLDatabaseByName("orders")
+	srv, err := cfg.ExternalServiceByName("payments")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := &testAppConfig{}
+	err = cfg.DecodeApp(app)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	switch {
+	case cfg.ServiceName() != "orders" || cfg.HTTPort() != 8080 || cfg.GRPCPort() != 8081:
+		t.Errorf("service fields not set: %+v", cfg.snapshot())
+	case db == nil || db.Address() != "localhost:5432" || db.PoolSettings().MaxOpenConns() != 10:
+		t.Errorf("database not set: %+v", db)
+	case srv.Address() != "localhost:9000":
+		t.Errorf("service address = %q", srv.Address())
+	case app.PaymentsURL != "https://payments.local" || app.Timeout == 0:
+		t.Errorf("app section not decoded: %+v", app)
+	}
+
+	// Changing the builder does not change built config
+	b.HTTPort(9090)
+	if cfg.HTTPort() != 8080 {
+		t.Errorf("HTTPort() = %d after builder changed, want 8080", cfg.HTTPort())
+	}
+}
+
+func TestBuilderErrors(t *testing.T) {
+	_, err := NewBuilder("orders").HTTPort(8080).Insecure().Set("unknown", "1").Build()
+	if err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Errorf("Build() error = %v, want unknown field error", err)
+	}
+
+	_, err = NewBuilder("").HTTPort(8080).Database(&DatabaseSpec{Type: "mongo"}).Build()
+
+	validationErr := &ValidationError{}
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("Build() error = %v, want *ValidationError", err)
+	}
+	for _, want := range []string{"serviceName", "databases[0].type", "security.tlsCert"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Build() error %q does not mention %s", err, want)
+		}
+	}
+}
@@ -2,14 +2,24 @@
 package config
 
 import (
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"os"
+
 	"github.com/pkg/errors"
 )
 
 type securityOptions struct {
-	TLSCertFile string `yaml:"tlsCert"`
-	TLSKeyFile  string `yaml:"tlsKey"`
-	ServerName  string `yaml:"serverName"`
-	Insecure    bool   `yaml:"insecure"`
+	TLSCertFile       string   `yaml:"tlsCert" file:"path"`
+	TLSKeyFile        string   `yaml:"tlsKey" file:"path"`
+	ServerName        string   `yaml:"serverName"`
+	Insecure          bool     `yaml:"insecure"`
+	CertReloadSeconds int      `yaml:"certReloadSeconds"`
+	MinVersion        string   `yaml:"minVersion"`
+	CipherSuites      []string `yaml:"cipherSuites"`
+	ClientCAFile      string   `yaml:"clientCA" file:"path"`
+	ClientAuth        string   `yaml:"clientAuth"`
 }
 
 type poolSettings struct {
@@ -18,11 +28,21 @@ type poolSettings struct {
 	MaxConnLifetimeSeconds uint `yaml:"maxConnLifetimeSeconds"`
 }
 
+type waitOptions struct {
+	MaxWaitSeconds       uint `yaml:"maxWaitSeconds"`
+	InitialBackoffMillis uint `yaml:"initialBackoffMillis"`
+	MaxBackoffSeconds    uint `yaml:"maxBackoffSeconds"`
+}
+
 type dbMetadata struct {
 	Name          string `yaml:"name"`
 	Dialect       string `yaml:"dialect"`
 	Orm           string `yaml:"orm"`
 	UseRediSearch bool   `yaml:"useRediSearch"`
+	// Postgres connection options
+	SSLMode    string `yaml:"sslMode"`
+	SearchPath string `yaml:"searchPath"`
+	TimeZone   string `yaml:"timeZone"`
 }
 
 // databaseOptions contains parameters that open connection to a database
@@ -30,67 +50,154 @@ type databaseOptions struct {
 	Required     bool          `yaml:"required"`
 	Type         string        `yaml:"type"`
 	Address      string        `yaml:"address"`
-	User         string        `yaml:"user"`
+	User         string        `yaml:"user" secret:"true"`
 	Schema       string        `yaml:"schema"`
-	Password     string        `yaml:"password"`
+	Password     string        `yaml:"password" secret:"true"`
 	UserFile     string        `yaml:"userFile"`
 	SchemaFile   string        `yaml:"schemaFile"`
 	PasswordFile string        `yaml:"passwordFile"`
 	PoolSettings *poolSettings `yaml:"poolSettings"`
+	WaitFor      *waitOptions  `yaml:"waitFor"`
 	Metadata     *dbMetadata   `yaml:"metadata"`
 }
 
 // externalServiceOptions contains information to connect to a remote service
 type externalServiceOptions struct {
-	Name        string `yaml:"name"`
-	Required    bool   `yaml:"required"`
-	K8Service   bool   `yaml:"k8service"`
-	Address     string `yaml:"address"`
-	TLSCertFile string `yaml:"tlsCert"`
-	ServerName  string `yaml:"serverName"`
-	Insecure    bool   `yaml:"insecure"`
+	Name        string       `yaml:"name"`
+	Required    bool         `yaml:"required"`
+	K8Service   bool         `yaml:"k8service"`
+	Address     string       `yaml:"address"`
+	TLSCertFile string       `yaml:"tlsCert" file:"path"`
+	ServerName  string       `yaml:"serverName"`
+	Insecure    bool         `yaml:"insecure"`
+	WaitFor     *waitOptions `yaml:"waitFor"`
+}
+
+// authOptions contains options for signing and verifying jwt tokens
+type authOptions struct {
+	Issuer           string   `yaml:"issuer"`
+	Audience         string   `yaml:"audience"`
+	SigningMethod    string   `yaml:"signingMethod"`
+	SigningKey       string   `yaml:"signingKey" secret:"true"`
+	SigningKeyFile   string   `yaml:"signingKeyFile"`
+	OtherSigningKeys []string `yaml:"otherSigningKeys" secret:"true"`
 }
 
 type httpOptions struct {
 	CorsEnabled bool `yaml:"corsEnabled"`
+	H2CEnabled  bool `yaml:"h2cEnabled"`
 }
 
 // config contains configuration parameters, options and settings for a micro-service
 type config struct {
-	ServiceName         string                    `yaml:"serviceName"`
-	ServiceType         string                    `yaml:"serviceType"`
-	HTTPort             int                       `yaml:"httpPort"`
-	GRPCPort            int                       `yaml:"grpcPort"`
-	HttpOtions          *httpOptions              `yaml:"httpOptions"`
-	StartupSleepSeconds int                       `yaml:"startupSleepSeconds"`
-	LogLevel            int                       `yaml:"logLevel"`
-	Security            *securityOptions          `yaml:"security"`
-	Databases           []*databaseOptions        `yaml:"databases"`
-	ExternalServices    []*externalServiceOptions `yaml:"externalServices"`
+	ServiceName          string                    `yaml:"serviceName"`
+	ServiceType          string                    `yaml:"serviceType"`
+	HTTPort              int                       `yaml:"httpPort"`
+	GRPCPort             int                       `yaml:"grpcPort"`
+	AdminPort            int                       `yaml:"adminPort"`
+	HttpOtions           *httpOptions              `yaml:"httpOptions"`
+	StartupSleepSeconds  int                       `yaml:"startupSleepSeconds"`
+	ShutdownDrainSeconds int                       `yaml:"shutdownDrainSeconds"`
+	LogLevel             int                       `yaml:"logLevel"`
+	Security             *securityOptions          `yaml:"security"`
+	Databases            []*databaseOptions        `yaml:"databases"`
+	ExternalServices     []*externalServiceOptions `yaml:"externalServices"`
+	Auth                 *authOptions              `yaml:"auth"`
+	App                  map[string]interface{}    `yaml:"app"`
+	// overrides for the app section applied when it is decoded
+	environ []string
+	appSets []string
+	source  *source
+	// yaml paths of fields read from files or secret providers
+	secretPaths map[string]bool
 }
 
-// Config contains configuration parameters, options and settings for a micro-service
+// Config contains configuration parameters, options and settings for a micro-service.
+// Use its getters rather than fields to read values, fields are not updated when the config is reloaded.
 type Config struct {
 	config
+	live *liveConfig
 }
 
-// New creates config by reading from first non-empty file specified in configFile argument or with --config-file flag
+// New creates config by reading from first non-empty file specified in configFile argument, defaulting to
+// configs/config.yml. Profiles selected with MICRO_PROFILE env are overlaid on the file, e.g staging overlays
+// config.staging.yml on config.yml. Databases and external services are merged by name.
+//
+// New does not define or parse command line flags. Earlier versions read --config-file, --profile and --set
+// from flag.CommandLine. To keep reading them, bind them with Options.BindFlags and call Load.
 func New(configFile ...string) (*Config, error) {
+	return Load(&Options{File: firstVal(append(configFile, "configs/config.yml")...)})
+}
+
+// Options contains options for loading config without parsing command line flags
+type Options struct {
+	// File is the config file. When FS is set it is a slash separated path in FS.
+	// Files ending in .json and .toml are read as json and toml, others as yaml
+	File string
+	// FS is the file system config files are read from, defaults to the OS file system.
+	// Included files with absolute paths are read from the OS file system.
+	FS fs.FS
+	// Reader reads the config file instead of File which is then only used in errors and to resolve
+	// relative paths of included files. Profiles are not supported
+	Reader io.Reader
+	// Profiles are overlaid in order on the file. Defaults to MICRO_PROFILE env
+	Profiles []string
+	// Sets override config values using their yaml path like the --set flag e.g databases.orders.address=localhost:3306
+	Sets []string
+	// SkipValidation returns config even when it is not valid e.g to inspect it
+	SkipValidation bool
+}
+
+// Load creates config from the files, profiles and overrides in opt, it can be called any number of times.
+// Environment variables override values from files as described in EnvPrefix. See Options.BindFlags to set
+// the options from flags.
+func Load(opt *Options) (*Config, error) {
+	if opt == nil {
+		return nil, errors.New("missing config options")
+	}
+
+	src := &source{
+		file:     opt.File,
+		profiles: opt.Profiles,
+		sets:     opt.Sets,
+		fsys:     opt.FS,
+	}
+
+	switch {
+	case opt.Reader != nil:
+		if len(opt.Profiles) > 0 {
+			return nil, errors.New("profiles are not supported when reading config from a reader")
+		}
+		if src.file == "" {
+			src.file = "config.yml"
+		}
+		content, err := ioutil.ReadAll(opt.Reader)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read config")
+		}
+		src.content = content
+	case opt.File == "":
+		return nil, errors.New("missing config file")
+	case len(opt.Profiles) == 0:
+		src.profiles = splitProfiles(os.Getenv(EnvProfile))
+	}
+
 	cfg := newConfig()
+	cfg.source = src
 
-	// Parse the config file
-	err := cfg.parse(configFile...)
+	err := cfg.load()
 	if err != nil {
 		return nil, err
 	}
 
-	// Validate config
-	err = errors.Wrap(cfg.validate(), "validation error")
-	if err != nil {
-		return nil, err
+	if !opt.SkipValidation {
+		err = errors.Wrap(cfg.validate(), "validation error")
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return &Config{*cfg}, nil
+	return newLiveConfig(cfg), nil
 }
 
 const unknownLevel = 1000
@@ -1,17 +1,25 @@
 package config
 
 import (
+	"fmt"
+	"io/fs"
 	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
 
 	"github.com/pkg/errors"
 	"gopkg.in/yaml.v2"
 )
 
-func (cfg *config) setConfigFromFile(filename string) error {
-	if filename == "" {
-		filename = "configs/config.yml"
-	}
-	cfgFromFile, err := readFromYAML(filename)
+// includeKey is the yaml key listing config fragments to include.
+// Included files are merged in order and the including file takes precedence over them, see mergeYAML.
+// Relative paths are resolved from the directory of the including file.
+const includeKey = "include"
+
+func (cfg *config) setConfigFromFile(src *source) error {
+	cfgFromFile, err := src.read()
 	if err != nil {
 		return errors.Wrap(err, "failed to read config from yaml file")
 	}
@@ -22,10 +30,29 @@ func (cfg *config) setConfigFromFile(filename string) error {
 	return nil
 }
 
-func readFromYAML(filename string) (*config, error) {
-	bs, err := ioutil.ReadFile(filename)
+// read reads the config file overlaid with the files of its profiles in order
+func (src *source) read() (*config, error) {
+	filename := src.file
+	if filename == "" {
+		filename = "configs/config.yml"
+	}
+
+	content, err := src.loadFile(filename, nil)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to read from file")
+		return nil, err
+	}
+
+	for _, profile := range src.profiles {
+		overlay, err := src.loadFile(profileFile(filename, profile), nil)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read config for profile %s", profile)
+		}
+		content = mergeYAML(content, overlay)
+	}
+
+	bs, err := yaml.Marshal(content)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal yaml")
 	}
 
 	cfg := newConfig()
@@ -37,3 +64,195 @@ func readFromYAML(filename string) (*config, error) {
 
 	return cfg, nil
 }
+
+// loadFile reads and interpolates filename, returning its content merged on top of the files it includes.
+// The format of each file is selected by its extension, see fileFormat.
+func (src *source) loadFile(filename string, parents []string) (map[interface{}]interface{}, error) {
+	key, err := src.key(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to resolve file path")
+	}
+	for _, parent := range parents {
+		if parent == key {
+			return nil, fmt.Errorf("include cycle detected at %s", filename)
+		}
+	}
+
+	bs, err := src.readFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read from file")
+	}
+
+	content, err := decodeFile(filename, bs)
+	if err != nil {
+		return nil, err
+	}
+
+	readRelative := func(name string) ([]byte, error) {
+		return src.readFile(src.resolve(filename, name))
+	}
+
+	// Variables are replaced in decoded values so that they cannot change the structure of the file
+	err = interpolate(content, os.LookupEnv, readRelative)
+	if err != nil {
+		return nil, errors.Wrapf(withLines(err, fieldLines(filename, bs)), "failed to interpolate variables in %s", filename)
+	}
+
+	// Validate the file on its own against the schema so that errors point to its lines
+	err = validateSchema(content)
+	if err != nil {
+		return nil, errors.Wrapf(withLines(err, fieldLines(filename, bs)), "invalid config file %s", filename)
+	}
+
+	includes, err := includePaths(content[includeKey])
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid %s in %s", includeKey, filename)
+	}
+	delete(content, includeKey)
+
+	merged := make(map[interface{}]interface{})
+	for _, include := range includes {
+		fragment, err := src.loadFile(src.resolve(filename, include), append(parents, key))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to include file in %s", filename)
+		}
+		merged = mergeYAML(merged, fragment)
+	}
+
+	return mergeYAML(merged, content), nil
+}
+
+// readFile reads a config file from the source file system. Absolute paths are always read from the OS file system.
+func (src *source) readFile(name string) ([]byte, error) {
+	switch {
+	case src.content != nil && name == src.file:
+		return src.content, nil
+	case src.fsys != nil && !filepath.IsAbs(name):
+		return fs.ReadFile(src.fsys, name)
+	default:
+		return ioutil.ReadFile(name)
+	}
+}
+
+// resolve returns the path of name relative to the directory of the file that references it
+func (src *source) resolve(filename, name string) string {
+	switch {
+	case filepath.IsAbs(name):
+		return name
+	case src.fsys != nil && !filepath.IsAbs(filename):
+		return path.Join(path.Dir(filename), name)
+	default:
+		return filepath.Join(filepath.Dir(filename), name)
+	}
+}
+
+// key identifies a file when detecting include cycles
+func (src *source) key(name string) (string, error) {
+	if src.fsys != nil && !filepath.IsAbs(name) {
+		return "fs:" + path.Clean(name), nil
+	}
+	return filepath.Abs(name)
+}
+
+// includePaths reads the include directive which is either a single path or a list of paths
+func includePaths(v interface{}) ([]string, error) {
+	switch val := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{val}, nil
+	case []interface{}:
+		paths := make([]string, 0, len(val))
+		for _, p := range val {
+			path, ok := p.(string)
+			if !ok || path == "" {
+				return nil, fmt.Errorf("expected file path, got %v", p)
+			}
+			paths = append(paths, path)
+		}
+		return paths, nil
+	default:
+		return nil, fmt.Errorf("expected file path or list of file paths, got %v", v)
+	}
+}
+
+// profileFile returns the overlay file of a profile e.g configs/config.staging.yml for configs/config.yml
+func profileFile(filename, profile string) string {
+	ext := filepath.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + "." + profile + ext
+}
+
+// listEntryNames are the keys holding lists merged by the name of their entries
+var listEntryNames = map[string]func(entry map[interface{}]interface{}) string{
+	"databases": func(entry map[interface{}]interface{}) string {
+		md, _ := entry["metadata"].(map[interface{}]interface{})
+		name, _ := md["name"].(string)
+		return name
+	},
+	"externalServices": func(entry map[interface{}]interface{}) string {
+		name, _ := entry["name"].(string)
+		return name
+	},
+}
+
+// mergeYAML merges override into the top level of a config. Databases and external services are merged by name.
+func mergeYAML(base, override map[interface{}]interface{}) map[interface{}]interface{} {
+	for key, val := range override {
+		keyStr, _ := key.(string)
+		baseList, ok1 := base[key].([]interface{})
+		overrideList, ok2 := val.([]interface{})
+		if nameFn, ok := listEntryNames[keyStr]; ok && ok1 && ok2 {
+			base[key] = mergeYAMLList(baseList, overrideList, nameFn)
+			continue
+		}
+		base = mergeYAMLMap(base, map[interface{}]interface{}{key: val})
+	}
+	return base
+}
+
+// mergeYAMLMap merges override into base recursively. Maps are merged, other values in override replace those in base.
+func mergeYAMLMap(base, override map[interface{}]interface{}) map[interface{}]interface{} {
+	for key, val := range override {
+		baseMap, ok1 := base[key].(map[interface{}]interface{})
+		overrideMap, ok2 := val.(map[interface{}]interface{})
+		if ok1 && ok2 {
+			base[key] = mergeYAMLMap(baseMap, overrideMap)
+			continue
+		}
+		base[key] = val
+	}
+	return base
+}
+
+// mergeYAMLList merges entries in override into entries in base with the same name, appending the rest
+func mergeYAMLList(base, override []interface{}, nameFn func(map[interface{}]interface{}) string) []interface{} {
+	indexes := make(map[string]int, len(base))
+	for i, entry := range base {
+		if entryMap, ok := entry.(map[interface{}]interface{}); ok {
+			if name := nameFn(entryMap); name != "" {
+				indexes[name] = i
+			}
+		}
+	}
+
+	for _, entry := range override {
+		entryMap, ok := entry.(map[interface{}]interface{})
+		if !ok {
+			base = append(base, entry)
+			continue
+		}
+
+		name := nameFn(entryMap)
+		if i, ok := indexes[name]; ok && name != "" {
+			base[i] = mergeYAMLMap(base[i].(map[interface{}]interface{}), entryMap)
+			continue
+		}
+
+		if name != "" {
+			indexes[name] = len(base)
+		}
+		base = append(base, entryMap)
+	}
+
+	return base
+}
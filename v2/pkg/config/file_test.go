@@ -0,0 +1,85 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReadFromYAMLProfiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"config.yml": `
+serviceName: orders
+httpPort: 8080
+databases:
+  - type: sqlDatabase
+    address: localhost:3306
+    user: root
+    metadata:
+      name: orders
+      orm: gorm
+  - type: redisDatabase
+    address: localhost:6379
+    metadata:
+      name: cache
+externalServices:
+  - name: payments
+    address: localhost:9000
+    insecure: true
+`,
+		"config.staging.yml": `
+httpPort: 80
+databases:
+  - address: orders.staging:3306
+    metadata:
+      name: orders
+  - type: redisDatabase
+    address: sessions.staging:6379
+    metadata:
+      name: sessions
+externalServices:
+  - name: payments
+    address: payments.staging:443
+`,
+		"config.debug.yml": "logLevel: 5\n",
+	}
+	for name, content := range files {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, err := (&source{file: filepath.Join(dir, "config.yml"), profiles: []string{"staging", "debug"}}).read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	switch {
+	case cfg.ServiceName != "orders" || cfg.HTTPort != 80 || cfg.LogLevel != 5:
+		t.Errorf("top level fields not overlaid: %+v", cfg)
+	case len(cfg.Databases) != 3:
+		t.Fatalf("got %d databases, want 3", len(cfg.Databases))
+	case cfg.Databases[0].Address != "orders.staging:3306" || cfg.Databases[0].User != "root":
+		t.Errorf("databases not merged by name: %+v", cfg.Databases[0])
+	case cfg.Databases[0].Metadata.Orm != "gorm":
+		t.Errorf("database metadata not deep merged: %+v", cfg.Databases[0].Metadata)
+	case cfg.Databases[2].Metadata.Name != "sessions":
+		t.Errorf("new database not appended: %+v", cfg.Databases[2])
+	case len(cfg.ExternalServices) != 1 || cfg.ExternalServices[0].Address != "payments.staging:443" ||
+		!cfg.ExternalServices[0].Insecure:
+		t.Errorf("external services not merged by name: %+v", cfg.ExternalServices)
+	}
+
+	_, err = (&source{file: filepath.Join(dir, "config.yml"), profiles: []string{"missing"}}).read()
+	if err == nil {
+		t.Error("expected error for missing profile file")
+	}
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// Formats of config files and dumped config
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+	FormatTOML = "toml"
+)
+
+// fileFormat returns the format of a config file from its extension, yaml unless it is .json or .toml
+func fileFormat(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		return FormatJSON
+	case ".toml":
+		return FormatTOML
+	default:
+		return FormatYAML
+	}
+}
+
+// decodeFile decodes the content of a config file into the generic values produced by yaml,
+// so that files of every format are validated and merged the same way
+func decodeFile(filename string, bs []byte) (map[interface{}]interface{}, error) {
+	switch fileFormat(filename) {
+	case FormatJSON:
+		dec := json.NewDecoder(bytes.NewReader(bs))
+		dec.UseNumber()
+
+		content := make(map[string]interface{})
+		err := dec.Decode(&content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal json file %s: %v", filename, err)
+		}
+		return yamlValue(content).(map[interface{}]interface{}), nil
+
+	case FormatTOML:
+		content := make(map[string]interface{})
+		err := toml.Unmarshal(bs, &content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal toml file %s: %v", filename, err)
+		}
+		return yamlValue(content).(map[interface{}]interface{}), nil
+
+	default:
+		// Strict unmarshalling reports duplicate keys along with their lines
+		content := make(map[interface{}]interface{})
+		err := yaml.UnmarshalStrict(bs, &content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal yaml file %s: %v", filename, err)
+		}
+
+		return content, nil
+	}
+}
+
+// yamlValue converts values decoded from json and toml to the values decoded by yaml
+func yamlValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		m := make(map[interface{}]interface{}, len(val))
+		for key, elem := range val {
+			m[key] = yamlValue(elem)
+		}
+		return m
+	case []map[string]interface{}:
+		list := make([]interface{}, 0, len(val))
+		for _, elem := range val {
+			list = append(list, yamlValue(elem))
+		}
+		return list
+	case []interface{}:
+		list := make([]interface{}, 0, len(val))
+		for _, elem := range val {
+			list = append(list, yamlValue(elem))
+		}
+		return list
+	case json.Number:
+		if n, err := val.Int64(); err == nil {
+			return yamlValue(n)
+		}
+		f, _ := val.Float64()
+		return f
+	case int64:
+		if int64(int(val)) == val {
+			return int(val)
+		}
+		return val
+	case time.Time:
+		return val.Format(time.RFC3339Nano)
+	}
+	return v
+}
+
+// fieldLines returns the lines of the fields of a yaml or json config file by their yaml path.
+// Toml files and files that cannot be parsed have no lines. yaml.v2, which decodes config files, does not
+// report positions so files are parsed again with yaml.v3, only when their errors are reported.
+func fieldLines(filename string, bs []byte) map[string]int {
+	lines := make(map[string]int)
+	if fileFormat(filename) == FormatTOML {
+		return lines
+	}
+
+	var doc yamlv3.Node
+	if yamlv3.Unmarshal(bs, &doc) != nil || len(doc.Content) == 0 {
+		return lines
+	}
+	nodeLines(doc.Content[0], "", lines)
+
+	return lines
+}
+
+func nodeLines(node *yamlv3.Node, path string, lines map[string]int) {
+	switch node.Kind {
+	case yamlv3.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyPath := joinPath(path, node.Content[i].Value)
+			lines[keyPath] = node.Content[i].Line
+			nodeLines(node.Content[i+1], keyPath, lines)
+		}
+	case yamlv3.SequenceNode:
+		for i, elem := range node.Content {
+			elemPath := fmt.Sprintf("%s[%d]", path, i)
+			lines[elemPath] = elem.Line
+			nodeLines(elem, elemPath, lines)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFileFormats(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config.json": {Data: []byte(`{
+  "include": "databases.toml",
+  "serviceName": "orders",
+  "httpPort": 8080,
+  "grpcPort": 8081,
+  "security": {"insecure": true},
+  "app": {"limits": {"maxItems": 20}}
+}`)},
+		"config.staging.json": {Data: []byte(`{"logLevel": 3}`)},
+		"databases.toml": {Data: []byte(`
+[[databases]]
+type = "sqlDatabase"
+address = "localhost:5432"
+
+  [databases.metadata]
+  name = "orders"
+  dialect = "postgres"
+
+  [databases.poolSettings]
+  maxOpenConns = 10
+`)},
+		"bad.json":  {Data: []byte(`{"httpPort": "8080"}`)},
+		"bad.toml":  {Data: []byte("httpPort = \n")},
+		"enum.toml": {Data: []byte("[[databases]]\ntype = \"sql\"\n")},
+	}
+
+	cfg, err := (&source{file: "config.json", profiles: []string{"staging"}, fsys: fsys}).read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	switch {
+	case cfg.ServiceName != "orders" || cfg.HTTPort != 8080 || cfg.LogLevel != 3:
+		t.Errorf("json fields not read: %+v", cfg)
+	case len(cfg.Databases) != 1 || cfg.Databases[0].Metadata.Dialect != "postgres":
+		t.Fatalf("toml include not read: %+v", cfg.Databases)
+	case cfg.Databases[0].PoolSettings.MaxOpenConns != 10:
+		t.Errorf("toml integers not read: %+v", cfg.Databases[0].PoolSettings)
+	}
+
+	tests := []struct {
+		file    string
+		wantErr string
+	}{
+		{file: "bad.json", wantErr: `httpPort: expected integer, got string`},
+		{file: "bad.toml", wantErr: "failed to unmarshal toml file bad.toml"},
+		{file: "enum.toml", wantErr: `databases[0].type: value "sql" must be one of`},
+	}
+
+	for _, tt := range tests {
+		_, err := (&source{file: tt.file, fsys: fsys}).read()
+		if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("read(%s) error = %v, want %q", tt.file, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDumpReadBack(t *testing.T) {
+	built, err := NewBuilder("orders").HTTPort(8080).GRPCPort(8081).Insecure().
+		Database(&DatabaseSpec{Name: "cache", Type: RedisDBType, Address: "localhost:6379"}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{FormatYAML, FormatJSON, FormatTOML} {
+		buf := &bytes.Buffer{}
+		err = built.Dump(buf, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cfg, err := Load(&Options{File: "config." + format, Reader: buf})
+		if err != nil {
+			t.Fatalf("Load() %s dump error = %v", format, err)
+		}
+		if cfg.ServiceName() != "orders" || cfg.RedisDatabaseByName("cache") == nil {
+			t.Errorf("%s dump not read back: %+v", format, cfg.snapshot())
+		}
+	}
+}
+
+func TestFieldErrorLines(t *testing.T) {
+	tests := []struct {
+		file    string
+		content string
+		want    string
+	}{
+		{"config.yml", "serviceName: orders\ndatabases:\n  - type: sqlDatabase\n    adress: localhost\n", "line 4: databases[0].adress: unknown field"},
+		{"config.json", "{\n  \"serviceName\": \"orders\",\n  \"httpPort\": \"http\"\n}\n", "line 3: httpPort: expected integer"},
+		{"config.yml", "serviceName: orders\nhttpPort: ${CONFIG_TEST_UNSET_PORT}\n", "line 2: httpPort: unresolved variable"},
+		{"config.toml", "serviceName = \"orders\"\nunknown = true\n", "unknown: unknown field"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			fsys := fstest.MapFS{tt.file: {Data: []byte(tt.content)}}
+
+			_, err := (&source{file: tt.file, fsys: fsys}).read()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("read() error = %v, want error containing %q", err, tt.want)
+			}
+		})
+	}
+}
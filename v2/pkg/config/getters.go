@@ -3,60 +3,110 @@ package config
 import (
 	"fmt"
 	"strings"
+	"time"
 )
 
 // ServiceName returns the service name
 func (cfg *Config) ServiceName() string {
-	return cfg.config.ServiceName
+	return cfg.snapshot().ServiceName
 }
 
 // ServicePort returns the service http port
 func (cfg *Config) ServicePort() int {
-	return cfg.config.HTTPort
+	return cfg.snapshot().HTTPort
 }
 
 // ServiceType returns the type k8s service to be used for exposing the app
 func (cfg *Config) ServiceType() string {
-	return cfg.config.ServiceType
+	return cfg.snapshot().ServiceType
 }
 
 // GRPCPort returns the service grpc port or 8080 if no port was is specifiedin config
 func (cfg *Config) GRPCPort() int {
-	if cfg.config.GRPCPort == 0 {
+	if cfg.snapshot().GRPCPort == 0 {
 		return 8080
 	}
 
-	return cfg.config.GRPCPort
+	return cfg.snapshot().GRPCPort
+}
+
+// AdminPort returns the port for the internal admin server or 0 if the admin server is disabled
+func (cfg *Config) AdminPort() int {
+	return cfg.snapshot().AdminPort
 }
 
 // HTTPort returns the http port for service
 func (cfg *Config) HTTPort() int {
-	return cfg.config.HTTPort
+	return cfg.snapshot().HTTPort
+}
+
+// ShutdownDrainSeconds returns the period to wait after readiness starts failing and before the servers are stopped
+func (cfg *Config) ShutdownDrainSeconds() int {
+	return cfg.snapshot().ShutdownDrainSeconds
 }
 
 // StartupSleepSeconds returns the startup sleep period
+//
+// Deprecated: dependencies are now probed with backoff at startup, see WaitOptions
 func (cfg *Config) StartupSleepSeconds() int {
-	return cfg.config.StartupSleepSeconds
+	return cfg.snapshot().StartupSleepSeconds
 }
 
 // ServiceTLSCertFile returns path to file containing tls certificate for the service
 func (cfg *Config) ServiceTLSCertFile() string {
-	return cfg.config.Security.TLSCertFile
+	return cfg.snapshot().Security.TLSCertFile
 }
 
 // ServiceTLSKeyFile returns path to file containing tls private key for the service if any
 func (cfg *Config) ServiceTLSKeyFile() string {
-	return cfg.config.Security.TLSKeyFile
+	return cfg.snapshot().Security.TLSKeyFile
 }
 
 // ServiceTLSServerName returns tls server name of the service
 func (cfg *Config) ServiceTLSServerName() string {
-	return cfg.config.Security.ServerName
+	return cfg.snapshot().Security.ServerName
+}
+
+// ServiceTLSCertReloadInterval returns how often the service tls certificate and key files are checked for changes.
+// Defaults to one minute, a negative value in config disables reloading.
+func (cfg *Config) ServiceTLSCertReloadInterval() time.Duration {
+	switch {
+	case cfg.snapshot().Security.CertReloadSeconds < 0:
+		return 0
+	case cfg.snapshot().Security.CertReloadSeconds == 0:
+		return time.Minute
+	}
+	return time.Duration(cfg.snapshot().Security.CertReloadSeconds) * time.Second
+}
+
+// ServiceTLSMinVersion returns the minimum tls version accepted by the service e.g "1.2"
+func (cfg *Config) ServiceTLSMinVersion() string {
+	return cfg.snapshot().Security.MinVersion
+}
+
+// ServiceTLSCipherSuites returns the names of cipher suites accepted by the service for tls 1.2 and lower
+func (cfg *Config) ServiceTLSCipherSuites() []string {
+	return cfg.snapshot().Security.CipherSuites
+}
+
+// ServiceTLSClientCAFile returns path to the CA bundle used to verify client certificates
+func (cfg *Config) ServiceTLSClientCAFile() string {
+	return cfg.snapshot().Security.ClientCAFile
+}
+
+// ServiceTLSClientAuth returns the client authentication mode, one of none, request or require-and-verify
+func (cfg *Config) ServiceTLSClientAuth() string {
+	return cfg.snapshot().Security.ClientAuth
 }
 
 // ServiceTLSEnabled checks whether tls is enabled for the service
 func (cfg *Config) ServiceTLSEnabled() bool {
-	return !cfg.config.Security.Insecure
+	return !cfg.snapshot().Security.Insecure
+}
+
+// SinglePort checks whether gRPC and REST are served on the service port, either over TLS or cleartext HTTP/2
+func (cfg *Config) SinglePort() bool {
+	return cfg.ServiceTLSEnabled() || cfg.snapshot().HttpOtions.H2CEnabled
 }
 
 // Security prevent the struct field from being accidentally overriden
@@ -64,14 +114,19 @@ func (cfg *Config) Security() {}
 
 // LogLevel returns log-level for logger
 func (cfg *Config) LogLevel() int {
-	if cfg.config.LogLevel == unknownLevel {
+	if cfg.snapshot().LogLevel == unknownLevel {
 		return 0
 	}
-	return cfg.config.LogLevel
+	return cfg.snapshot().LogLevel
+}
+
+// LogLevelSet checks whether the log level is set in the config
+func (cfg *Config) LogLevelSet() bool {
+	return cfg.snapshot().LogLevel != unknownLevel
 }
 
 func (cfg *Config) HttpOptions() *HttpOptions {
-	return &HttpOptions{cfg.config.HttpOtions}
+	return &HttpOptions{cfg.snapshot().HttpOtions}
 }
 
 type HttpOptions struct {
@@ -82,14 +137,19 @@ func (opt *HttpOptions) CorsEnabled() bool {
 	return opt.httpOptions.CorsEnabled
 }
 
+// H2CEnabled checks whether gRPC and REST are served on the same cleartext port using HTTP/2 without TLS
+func (opt *HttpOptions) H2CEnabled() bool {
+	return opt.httpOptions.H2CEnabled
+}
+
 // Database prevents this field from being accidentally overriden
 func (cfg *Config) Database() {}
 
 // Databases returns list of all databases options
 func (cfg *Config) Databases() []*DatabaseInfo {
-	dbs := make([]*DatabaseInfo, 0, len(cfg.config.Databases))
+	dbs := make([]*DatabaseInfo, 0, len(cfg.snapshot().Databases))
 
-	for _, db := range cfg.config.Databases {
+	for _, db := range cfg.snapshot().Databases {
 		dbs = append(dbs, &DatabaseInfo{db})
 	}
 
@@ -125,6 +185,30 @@ func (md *DatabaseMetadata) ORM() string {
 	return ""
 }
 
+// SSLMode returns the postgres ssl mode e.g require or verify-full
+func (md *DatabaseMetadata) SSLMode() string {
+	if md != nil && md.dbMetadata != nil {
+		return md.dbMetadata.SSLMode
+	}
+	return ""
+}
+
+// SearchPath returns the postgres schema search path
+func (md *DatabaseMetadata) SearchPath() string {
+	if md != nil && md.dbMetadata != nil {
+		return md.dbMetadata.SearchPath
+	}
+	return ""
+}
+
+// TimeZone returns the time zone of postgres sessions
+func (md *DatabaseMetadata) TimeZone() string {
+	if md != nil && md.dbMetadata != nil {
+		return md.dbMetadata.TimeZone
+	}
+	return ""
+}
+
 type PoolSettings struct {
 	*poolSettings
 }
@@ -153,6 +237,41 @@ func (ps *PoolSettings) MaxConnLifetimeSeconds() uint {
 	return 0
 }
 
+const (
+	defaultMaxWaitSeconds       = 60
+	defaultInitialBackoffMillis = 200
+	defaultMaxBackoffSeconds    = 10
+)
+
+// WaitOptions contains options for waiting for a dependency to become available at startup
+type WaitOptions struct {
+	*waitOptions
+}
+
+// MaxWait returns the maximum duration to wait for the dependency, defaults to 60 seconds
+func (wo *WaitOptions) MaxWait() time.Duration {
+	if wo.waitOptions != nil && wo.waitOptions.MaxWaitSeconds != 0 {
+		return time.Duration(wo.waitOptions.MaxWaitSeconds) * time.Second
+	}
+	return defaultMaxWaitSeconds * time.Second
+}
+
+// InitialBackoff returns the delay before the first retry, defaults to 200 milliseconds
+func (wo *WaitOptions) InitialBackoff() time.Duration {
+	if wo.waitOptions != nil && wo.waitOptions.InitialBackoffMillis != 0 {
+		return time.Duration(wo.waitOptions.InitialBackoffMillis) * time.Millisecond
+	}
+	return defaultInitialBackoffMillis * time.Millisecond
+}
+
+// MaxBackoff returns the maximum delay between retries, defaults to 10 seconds
+func (wo *WaitOptions) MaxBackoff() time.Duration {
+	if wo.waitOptions != nil && wo.waitOptions.MaxBackoffSeconds != 0 {
+		return time.Duration(wo.waitOptions.MaxBackoffSeconds) * time.Second
+	}
+	return defaultMaxBackoffSeconds * time.Second
+}
+
 // DatabaseInfo contains parameters for connecting to a database
 type DatabaseInfo struct {
 	*databaseOptions
@@ -227,11 +346,16 @@ func (db *DatabaseInfo) Metadata() *DatabaseMetadata {
 	return &DatabaseMetadata{db.databaseOptions.Metadata}
 }
 
-// Metadata is contains metadata information for the database
+// PoolSettings contains connection pool settings for the database
 func (db *DatabaseInfo) PoolSettings() *PoolSettings {
 	return &PoolSettings{db.databaseOptions.PoolSettings}
 }
 
+// WaitOptions contains options for waiting for the database to become available at startup
+func (db *DatabaseInfo) WaitOptions() *WaitOptions {
+	return &WaitOptions{db.databaseOptions.WaitFor}
+}
+
 // UseGorm indicates whether the service will use Object Relational Mapper for database operations
 func (db *DatabaseInfo) UseGorm() bool {
 	if db != nil && db.databaseOptions != nil &&
@@ -262,7 +386,7 @@ func (db *DatabaseInfo) SQLDatabaseDialect() string {
 
 // SQLDatabase returns the first sql database options for the service
 func (cfg *Config) SQLDatabase() *DatabaseInfo {
-	for _, db := range cfg.config.Databases {
+	for _, db := range cfg.snapshot().Databases {
 		if db.Type == SQLDBType {
 			return &DatabaseInfo{db}
 		}
@@ -272,7 +396,7 @@ func (cfg *Config) SQLDatabase() *DatabaseInfo {
 
 // SQLDatabaseByName returns the first sql database options with the given name
 func (cfg *Config) SQLDatabaseByName(identifier string) *DatabaseInfo {
-	for _, db := range cfg.config.Databases {
+	for _, db := range cfg.snapshot().Databases {
 		if db.Type == SQLDBType && db.Metadata.Name == identifier {
 			return &DatabaseInfo{db}
 		}
@@ -282,7 +406,7 @@ func (cfg *Config) SQLDatabaseByName(identifier string) *DatabaseInfo {
 
 // UseSQLDatabase indicates whether the service has sql database options
 func (cfg *Config) UseSQLDatabase() bool {
-	for _, db := range cfg.config.Databases {
+	for _, db := range cfg.snapshot().Databases {
 		if db.Type == SQLDBType && db.Required {
 			return true
 		}
@@ -292,7 +416,7 @@ func (cfg *Config) UseSQLDatabase() bool {
 
 // RedisDatabase returns the first redis database options for the service
 func (cfg *Config) RedisDatabase() *DatabaseInfo {
-	for _, db := range cfg.config.Databases {
+	for _, db := range cfg.snapshot().Databases {
 		if db.Type == RedisDBType {
 			return &DatabaseInfo{db}
 		}
@@ -302,7 +426,7 @@ func (cfg *Config) RedisDatabase() *DatabaseInfo {
 
 // RedisDatabaseByName returns the first redis database options with the given name
 func (cfg *Config) RedisDatabaseByName(name string) *DatabaseInfo {
-	for _, db := range cfg.config.Databases {
+	for _, db := range cfg.snapshot().Databases {
 		if db.Type == RedisDBType && db.Metadata != nil {
 			if db.Metadata.Name == name {
 				return &DatabaseInfo{db}
@@ -314,7 +438,7 @@ func (cfg *Config) RedisDatabaseByName(name string) *DatabaseInfo {
 
 // UseRedis returns whether service has redis options
 func (cfg *Config) UseRedis() bool {
-	for _, db := range cfg.config.Databases {
+	for _, db := range cfg.snapshot().Databases {
 		if db.Type == RedisDBType && db.Required {
 			return true
 		}
@@ -324,7 +448,7 @@ func (cfg *Config) UseRedis() bool {
 
 // UseRediSearch returns whether service has redisearch options
 func (cfg *Config) UseRediSearch() bool {
-	for _, db := range cfg.config.Databases {
+	for _, db := range cfg.snapshot().Databases {
 		if db.Type == RedisDBType && db.Metadata.UseRediSearch {
 			return true
 		}
@@ -339,8 +463,8 @@ type ServiceInfo struct {
 
 // ExternalServices returns the list of available services
 func (cfg *Config) ExternalServices() []*ServiceInfo {
-	srvsInfo := make([]*ServiceInfo, 0, len(cfg.config.ExternalServices))
-	for _, extSrv := range cfg.config.ExternalServices {
+	srvsInfo := make([]*ServiceInfo, 0, len(cfg.snapshot().ExternalServices))
+	for _, extSrv := range cfg.snapshot().ExternalServices {
 		srvsInfo = append(srvsInfo, &ServiceInfo{extSrv})
 	}
 	return srvsInfo
@@ -381,6 +505,11 @@ func (srv *ServiceInfo) Insecure() bool {
 	return srv.externalServiceOptions.Insecure
 }
 
+// WaitOptions contains options for waiting for the service to become available at startup
+func (srv *ServiceInfo) WaitOptions() *WaitOptions {
+	return &WaitOptions{srv.externalServiceOptions.WaitFor}
+}
+
 // ExternalServiceByName first service whose name matches the passed service name.
 // The name comparison is case-insentive.
 func (cfg *Config) ExternalServiceByName(serviceName string) (*ServiceInfo, error) {
@@ -391,3 +520,57 @@ func (cfg *Config) ExternalServiceByName(serviceName string) (*ServiceInfo, erro
 	}
 	return nil, fmt.Errorf("no service found with name: %s", serviceName)
 }
+
+// Auth returns options for signing and verifying jwt tokens
+func (cfg *Config) Auth() *AuthOptions {
+	auth := cfg.snapshot().Auth
+	if auth == nil {
+		auth = &authOptions{}
+	}
+	return &AuthOptions{auth}
+}
+
+// AuthOptions contains options for signing and verifying jwt tokens
+type AuthOptions struct {
+	*authOptions
+}
+
+// Enabled checks whether a signing key is configured
+func (opt *AuthOptions) Enabled() bool {
+	return opt.authOptions.SigningKey != ""
+}
+
+// Issuer returns the issuer of tokens
+func (opt *AuthOptions) Issuer() string {
+	return opt.authOptions.Issuer
+}
+
+// Audience returns the audience of tokens
+func (opt *AuthOptions) Audience() string {
+	return opt.authOptions.Audience
+}
+
+// SigningMethod returns the jwt signing method name, defaults to HS256
+func (opt *AuthOptions) SigningMethod() string {
+	if opt.authOptions.SigningMethod == "" {
+		return defaultSigningMethod
+	}
+	return opt.authOptions.SigningMethod
+}
+
+// SigningKey returns the key used to sign tokens
+func (opt *AuthOptions) SigningKey() []byte {
+	if opt.authOptions.SigningKey == "" {
+		return nil
+	}
+	return []byte(opt.authOptions.SigningKey)
+}
+
+// OtherSigningKeys returns other keys accepted when verifying tokens e.g during key rotation
+func (opt *AuthOptions) OtherSigningKeys() [][]byte {
+	keys := make([][]byte, 0, len(opt.authOptions.OtherSigningKeys))
+	for _, key := range opt.authOptions.OtherSigningKeys {
+		keys = append(keys, []byte(key))
+	}
+	return keys
+}
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// interpolate replaces variables in the string values of decoded config content, so that substituted values
+// never change the structure of yaml, json or toml files.
+//
+// The supported forms are ${VAR} which fails if VAR is not set, ${VAR:-default} which uses default if VAR
+// is unset or empty and ${file:/path} which is replaced by the trimmed content of the file read with readFile,
+// relative paths are resolved from the directory of the interpolated file. Braces in defaults must be balanced
+// and defaults may contain variables. Use $${ to write a literal ${. Keys and comments are not interpolated.
+//
+// A value consisting of a single variable takes the type of its field in the config schema e.g httpPort: ${PORT},
+// json and toml files must quote such values. Other values, including app values, stay strings.
+func interpolate(
+	content map[interface{}]interface{}, lookupEnv func(string) (string, bool), readFile func(string) ([]byte, error),
+) error {
+	v := &validator{}
+	interpolateValue(v, "", content, configSchema(), lookupEnv, readFile)
+	return v.err()
+}
+
+func interpolateValue(
+	v *validator, path string, val interface{}, schema *jsonSchema,
+	lookupEnv func(string) (string, bool), readFile func(string) ([]byte, error),
+) interface{} {
+	switch val := val.(type) {
+	case map[interface{}]interface{}:
+		for key, elem := range val {
+			val[key] = interpolateValue(v, joinPath(path, fmt.Sprint(key)), elem, schema.property(fmt.Sprint(key)), lookupEnv, readFile)
+		}
+		return val
+	case []interface{}:
+		for i, elem := range val {
+			val[i] = interpolateValue(v, fmt.Sprintf("%s[%d]", path, i), elem, schema.items(), lookupEnv, readFile)
+		}
+		return val
+	case string:
+		if !strings.Contains(val, "${") {
+			return val
+		}
+		res, err := interpolateString(val, schema, lookupEnv, readFile)
+		if err != nil {
+			v.addf(path, "%v", err)
+			return val
+		}
+		return res
+	default:
+		return val
+	}
+}
+
+// interpolateString replaces the variables in s. If s is a single variable the value is typed, see scalarValue.
+func interpolateString(
+	s string, schema *jsonSchema, lookupEnv func(string) (string, bool), readFile func(string) ([]byte, error),
+) (interface{}, error) {
+	res, single, err := expandString(s, lookupEnv, readFile)
+	if err != nil {
+		return nil, err
+	}
+	if single {
+		return scalarValue(res, schema), nil
+	}
+	return res, nil
+}
+
+// expandString replaces the variables in s, reporting whether s consists of a single variable
+func expandString(s string, lookupEnv func(string) (string, bool), readFile func(string) ([]byte, error)) (string, bool, error) {
+	var (
+		b      strings.Builder
+		single = false
+		line   = s
+	)
+
+	for {
+		start := strings.Index(line, "${")
+		if start < 0 {
+			b.WriteString(line)
+			return b.String(), single, nil
+		}
+
+		// Escaped as $${
+		if start > 0 && line[start-1] == '$' {
+			b.WriteString(line[:start])
+			b.WriteString("{")
+			line = line[start+2:]
+			continue
+		}
+
+		end := variableEnd(line, start)
+		if end < 0 {
+			return "", false, fmt.Errorf("unterminated variable %s", line[start:])
+		}
+
+		val, err := resolveVariable(line[start+2:end], lookupEnv, readFile)
+		if err != nil {
+			return "", false, err
+		}
+
+		single = line == s && start == 0 && end == len(s)-1
+
+		b.WriteString(line[:start])
+		b.WriteString(val)
+		line = line[end+1:]
+	}
+}
+
+// variableEnd returns the index of the brace closing the variable starting at start, counting nested braces
+func variableEnd(s string, start int) int {
+	depth := 0
+	for i := start + 1; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// scalarValue converts a substituted value of an integer, number or boolean field the same way yaml resolves
+// plain scalars. Empty values are null so that the field is left unset. Values of other fields stay strings,
+// e.g a password of 12345 or yes.
+func scalarValue(s string, schema *jsonSchema) interface{} {
+	if s == "" {
+		return nil
+	}
+	if schema == nil || strings.ContainsAny(s, "\r\n") {
+		return s
+	}
+	switch schema.Type {
+	case "integer", "number", "boolean":
+	default:
+		return s
+	}
+
+	var val interface{}
+	err := yaml.Unmarshal([]byte(s), &val)
+	if err != nil {
+		return s
+	}
+
+	switch val.(type) {
+	case nil, bool, int, int64, uint64, float64:
+		return val
+	default:
+		return s
+	}
+}
+
+func resolveVariable(expr string, lookupEnv func(string) (string, bool), readFile func(string) ([]byte, error)) (string, error) {
+	if strings.HasPrefix(expr, "file:") {
+		name := strings.TrimSpace(strings.TrimPrefix(expr, "file:"))
+		if name == "" {
+			return "", fmt.Errorf("missing file path in variable ${%s}", expr)
+		}
+		bs, err := readFile(name)
+		if err != nil {
+			return "", fmt.Errorf("unresolved variable ${%s}: %v", expr, err)
+		}
+		return string(bytes.TrimSpace(bs)), nil
+	}
+
+	name, def, hasDef := expr, "", false
+	if i := strings.Index(expr, ":-"); i >= 0 {
+		name, def, hasDef = expr[:i], expr[i+2:], true
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", fmt.Errorf("missing variable name in ${%s}", expr)
+	}
+
+	val, ok := lookupEnv(name)
+	switch {
+	case hasDef && val == "":
+		res, _, err := expandString(def, lookupEnv, readFile)
+		return res, err
+	case !ok:
+		return "", fmt.Errorf("unresolved variable ${%s}: environment variable %s is not set", expr, name)
+	}
+
+	return val, nil
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestInterpolate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	pem := "-----BEGIN CERTIFICATE-----\nMIIB\n-----END CERTIFICATE-----"
+
+	for name, content := range map[string]string{"password": "s3cret\n", "cert.pem": pem + "\n"} {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	env := map[string]string{
+		"HOST": "db", "EMPTY": "", "PORT": "8080", "QUOTED": `say "hi": now`, "NUM": "12345", "HEX": "0x10", "YES": "yes",
+	}
+	lookup := func(name string) (string, bool) {
+		val, ok := env[name]
+		return val, ok
+	}
+
+	readFile := func(name string) ([]byte, error) {
+		return ioutil.ReadFile(filepath.Join(dir, name))
+	}
+
+	tests := []struct {
+		name     string
+		filename string
+		content  string
+		key      string
+		want     interface{}
+		wantErr  string
+	}{
+		{name: "env", content: "value: ${HOST}:3306", want: "db:3306"},
+		{name: "typed", content: "httpPort: ${PORT}", key: "httpPort", want: 8080},
+		{name: "typed boolean", content: "httpOptions:\n  corsEnabled: ${YES}", key: "httpOptions.corsEnabled", want: true},
+		{name: "numeric string", content: "databases:\n- password: ${NUM}", key: "databases.0.password", want: "12345"},
+		{name: "hex string", content: "databases:\n- password: ${HEX}", key: "databases.0.password", want: "0x10"},
+		{name: "boolean string", content: "auth:\n  signingKey: ${YES}", key: "auth.signingKey", want: "yes"},
+		{name: "unknown field", content: "value: ${PORT}", want: "8080"},
+		{name: "default when unset", content: "httpPort: ${MISSING:-80}", key: "httpPort", want: 80},
+		{name: "default when empty", content: "value: ${EMPTY:-root}", want: "root"},
+		{name: "default with braces", content: "value: '${MISSING:-{a}}'", want: "{a}"},
+		{name: "default with variable", content: "value: ${MISSING:-${HOST}}:3306", want: "db:3306"},
+		{name: "empty without default", content: "value: '${EMPTY}'", want: nil},
+		{name: "file", content: "value: ${file:password}", want: "s3cret"},
+		{name: "multi-line file", content: "value: ${file:cert.pem}\nother: 1", want: pem},
+		{name: "special characters", content: "value: ${QUOTED}", want: `say "hi": now`},
+		{name: "escaped", content: "value: $${HOST}", want: "${HOST}"},
+		{name: "comment", content: "# ${MISSING}\nvalue: 1", want: 1},
+		{name: "json", filename: "config.json", content: `{"value": "${QUOTED}"}`, want: `say "hi": now`},
+		{name: "json typed", filename: "config.json", content: `{"httpPort": "${PORT}"}`, key: "httpPort", want: 8080},
+		{name: "json multi-line file", filename: "config.json", content: `{"value": "${file:cert.pem}"}`, want: pem},
+		{name: "toml", filename: "config.toml", content: `value = "${file:cert.pem}"`, want: pem},
+		{name: "unset", content: "a: 1\nvalue: ${MISSING}", wantErr: "value: unresolved variable ${MISSING}"},
+		{name: "missing file", content: "value: ${file:nope}", wantErr: "value: unresolved variable ${file:nope}"},
+		{name: "unterminated", content: "value: ${HOST", wantErr: "value: unterminated variable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := tt.filename
+			if filename == "" {
+				filename = "config.yml"
+			}
+
+			content, err := decodeFile(filename, []byte(tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = interpolate(content, lookup, readFile)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("interpolate() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			key := tt.key
+			if key == "" {
+				key = "value"
+			}
+
+			if got := valueAt(content, key); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("interpolate() value = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+// valueAt returns the value at a dot separated path of keys and list indexes
+func valueAt(content map[interface{}]interface{}, path string) interface{} {
+	var val interface{} = content
+	for _, key := range strings.Split(path, ".") {
+		switch v := val.(type) {
+		case map[interface{}]interface{}:
+			val = v[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i >= len(v) {
+				return nil
+			}
+			val = v[i]
+		default:
+			return nil
+		}
+	}
+	return val
+}
+
+func TestReadFromYAMLInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"base.yml":   "serviceType: ClusterIp\nhttpPort: 80\nsecurity:\n  insecure: true\n  serverName: base\n",
+		"config.yml": "include: base.yml\nserviceName: orders\nhttpPort: 8080\nsecurity:\n  serverName: orders\n",
+		"cycle.yml":  "include: cycle.yml\n",
+		"bad.yml":    "include: base.yml\nserviceName: orders\nunknown: true\n",
+	}
+	for name, content := range files {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, err := (&source{file: filepath.Join(dir, "config.yml")}).read()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	switch {
+	case cfg.ServiceName != "orders" || cfg.ServiceType != "ClusterIp":
+		t.Errorf("fields from both files not merged: %+v", cfg)
+	case cfg.HTTPort != 8080:
+		t.Errorf("httpPort = %d, want 8080 from including file", cfg.HTTPort)
+	case !cfg.Security.Insecure || cfg.Security.ServerName != "orders":
+		t.Errorf("security not deep merged: %+v", cfg.Security)
+	}
+
+	_, err = (&source{file: filepath.Join(dir, "cycle.yml")}).read()
+	if err == nil || !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("expected include cycle error, got %v", err)
+	}
+
+	_, err = (&source{file: filepath.Join(dir, "bad.yml")}).read()
+	if err == nil || !strings.Contains(err.Error(), "bad.yml") || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("expected error pointing to bad.yml line 3, got %v", err)
+	}
+}
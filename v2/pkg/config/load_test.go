@@ -0,0 +1,149 @@
+package config
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+const loadTestConfig = "serviceName: orders\nhttpPort: 8080\ngrpcPort: 8081\nsecurity:\n  insecure: true\n"
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.yml")
+	err = ioutil.WriteFile(filename, []byte(loadTestConfig), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := fstest.MapFS{
+		"configs/base.yml":   {Data: []byte("serviceType: ClusterIp\nsecurity:\n  insecure: true\n")},
+		"configs/config.yml": {Data: []byte("include: base.yml\nserviceName: orders\nhttpPort: 8080\ngrpcPort: 8081\n")},
+		"configs/password":   {Data: []byte("s3cret\n")},
+		"configs/secret.yml": {Data: []byte("include: config.yml\nauth:\n  signingKey: ${file:password}\n")},
+	}
+
+	tests := []struct {
+		name    string
+		opt     *Options
+		check   func(cfg *Config) bool
+		wantErr string
+	}{
+		{
+			name:  "file",
+			opt:   &Options{File: filename, Sets: []string{"logLevel=3"}},
+			check: func(cfg *Config) bool { return cfg.ServiceName() == "orders" && cfg.LogLevel() == 3 },
+		},
+		{
+			name:  "file loaded again",
+			opt:   &Options{File: filename},
+			check: func(cfg *Config) bool { return cfg.ServiceName() == "orders" },
+		},
+		{
+			name: "fs with includes",
+			opt:  &Options{File: "configs/secret.yml", FS: fsys},
+			check: func(cfg *Config) bool {
+				return cfg.ServiceType() == "ClusterIp" && string(cfg.Auth().SigningKey()) == "s3cret"
+			},
+		},
+		{
+			name:  "reader",
+			opt:   &Options{Reader: strings.NewReader(loadTestConfig)},
+			check: func(cfg *Config) bool { return cfg.HTTPort() == 8080 },
+		},
+		{
+			name:    "reader with profiles",
+			opt:     &Options{Reader: strings.NewReader(loadTestConfig), Profiles: []string{"staging"}},
+			wantErr: "profiles are not supported",
+		},
+		{
+			name:    "invalid",
+			opt:     &Options{Reader: strings.NewReader("httpPort: 8080\n")},
+			wantErr: "serviceName: value is required",
+		},
+		{
+			name:  "skip validation",
+			opt:   &Options{Reader: strings.NewReader("httpPort: 8080\n"), SkipValidation: true},
+			check: func(cfg *Config) bool { return cfg.Validate() != nil },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(tt.opt)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(cfg) {
+				t.Errorf("Load() returned unexpected config %+v", cfg.snapshot())
+			}
+		})
+	}
+}
+
+func TestNewCalledAgain(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.yml")
+	err = ioutil.WriteFile(filename, []byte(loadTestConfig), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err = New(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Flags are left to the application, see Options.BindFlags
+	for _, name := range []string{"config-file", "profile", "set"} {
+		if flag.CommandLine.Lookup(name) != nil {
+			t.Errorf("New() defined flag %s on flag.CommandLine", name)
+		}
+	}
+}
+
+func TestBindFlags(t *testing.T) {
+	opt := &Options{}
+
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	opt.BindFlags(flagSet)
+
+	err := flagSet.Parse([]string{
+		"--config-file", "service.yml", "--profile", "staging, debug", "--set", "logLevel=3", "--set", "httpPort=80",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &Options{
+		File:     "service.yml",
+		Profiles: []string{"staging", "debug"},
+		Sets:     []string{"logLevel=3", "httpPort=80"},
+	}
+	if !reflect.DeepEqual(opt, want) {
+		t.Errorf("BindFlags() options = %+v, want %+v", opt, want)
+	}
+}
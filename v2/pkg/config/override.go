@@ -0,0 +1,299 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// EnvPrefix is the prefix of environment variables that override config values.
+//
+// The variable name is the prefix followed by the upper snake case yaml path of the field, e.g
+// MICRO_SERVICE_NAME overrides serviceName and MICRO_SECURITY_TLS_CERT overrides security.tlsCert.
+// Entries in databases and externalServices are addressed by their name or index, e.g
+// MICRO_DATABASES_ORDERS_ADDRESS overrides the address of the database named orders and
+// MICRO_EXTERNAL_SERVICES_0_ADDRESS overrides the address of the first external service.
+// Database names are read from metadata.name. List values such as security.cipherSuites are comma separated.
+//
+// Values are applied with precedence file < env < flags, where flags are passed as --set path=value
+// using the dotted yaml path e.g --set databases.orders.address=localhost:3306.
+// Overrides only apply to entries present in the config file, they do not create new entries.
+const EnvPrefix = "MICRO_"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// overrideField is a config field that can be overridden
+type overrideField struct {
+	path []string
+	set  func(value string) error
+}
+
+// envName is the environment variable overriding the field
+func (f *overrideField) envName() string {
+	parts := make([]string, 0, len(f.path))
+	for _, p := range f.path {
+		parts = append(parts, upperSnake(p))
+	}
+	return EnvPrefix + strings.Join(parts, "_")
+}
+
+// flagName is the dotted path used with the --set flag to override the field
+func (f *overrideField) flagName() string {
+	return strings.Join(f.path, ".")
+}
+
+// overrideFields lists the fields of cfg that can be overridden
+func (cfg *config) overrideFields() []*overrideField {
+	fields := make([]*overrideField, 0)
+	v := reflect.ValueOf(cfg).Elem()
+	walkOverrideFields(v.Type(), nil, func() reflect.Value { return v }, &fields)
+	return fields
+}
+
+// walkOverrideFields collects fields using their yaml names. Nil structs are only allocated when a field in them is set.
+func walkOverrideFields(t reflect.Type, path []string, get func() reflect.Value, fields *[]*overrideField) {
+	switch {
+	case t.Kind() == reflect.Ptr && t.Elem().Kind() == reflect.Struct:
+		walkOverrideFields(t.Elem(), path, func() reflect.Value {
+			p := get()
+			if p.IsNil() {
+				p.Set(reflect.New(t.Elem()))
+			}
+			return p.Elem()
+		}, fields)
+
+	case t.Kind() == reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			name := yamlName(t.Field(i))
+			if t.Field(i).PkgPath != "" || name == "-" {
+				continue
+			}
+			i := i
+			walkOverrideFields(t.Field(i).Type, appendPath(path, name), func() reflect.Value {
+				return get().Field(i)
+			}, fields)
+		}
+
+	case t.Kind() == reflect.Map || t.Kind() == reflect.Interface:
+		// Free form values such as the app section are overridden once decoded into a struct
+
+	case t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.String:
+		// Entries are addressed by index and by name
+		list := get()
+		for i := 0; i < list.Len(); i++ {
+			i := i
+			getEntry := func() reflect.Value { return get().Index(i) }
+
+			walkOverrideFields(t.Elem(), appendPath(path, strconv.Itoa(i)), getEntry, fields)
+
+			if name := entryName(list.Index(i)); name != "" {
+				walkOverrideFields(t.Elem(), appendPath(path, name), getEntry, fields)
+			}
+		}
+
+	default:
+		*fields = append(*fields, &overrideField{
+			path: path,
+			set: func(value string) error {
+				return setFieldValue(get(), value)
+			},
+		})
+	}
+}
+
+func appendPath(path []string, name string) []string {
+	return append(append(make([]string, 0, len(path)+1), path...), name)
+}
+
+// entryName returns the name of a database or external service entry
+func entryName(v reflect.Value) string {
+	switch entry := v.Interface().(type) {
+	case *databaseOptions:
+		if entry != nil && entry.Metadata != nil {
+			return entry.Metadata.Name
+		}
+	case *externalServiceOptions:
+		if entry != nil {
+			return entry.Name
+		}
+	}
+	return ""
+}
+
+func setFieldValue(v reflect.Value, value string) error {
+	value = strings.TrimSpace(value)
+
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return errors.Wrap(err, "failed to parse duration")
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return errors.Wrap(err, "failed to parse boolean")
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return errors.Wrap(err, "failed to parse integer")
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return errors.Wrap(err, "failed to parse unsigned integer")
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return errors.Wrap(err, "failed to parse number")
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported type %s", v.Type())
+		}
+		vals := reflect.MakeSlice(v.Type(), 0, 0)
+		for _, val := range strings.Split(value, ",") {
+			if val = strings.TrimSpace(val); val != "" {
+				vals = reflect.Append(vals, reflect.ValueOf(val).Convert(v.Type().Elem()))
+			}
+		}
+		v.Set(vals)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	return nil
+}
+
+// upperSnake converts a yaml name such as serviceName or orders-db to SERVICE_NAME or ORDERS_DB
+func upperSnake(name string) string {
+	var (
+		b    strings.Builder
+		prev rune
+	)
+	for i, r := range name {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			r = '_'
+		case i > 0 && unicode.IsUpper(r) && (unicode.IsLower(prev) || unicode.IsDigit(prev)):
+			b.WriteRune('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+		prev = r
+	}
+	return b.String()
+}
+
+// setConfigFromEnv overrides config values with environment variables prefixed with EnvPrefix.
+// Environment variables that do not match a config field are ignored.
+func (cfg *config) setConfigFromEnv(environ []string) error {
+	// Kept for overriding fields in the app section when it is decoded
+	cfg.environ = environ
+
+	return applyEnvOverrides(cfg.overrideFields(), environ)
+}
+
+// setConfigFromFlags overrides config values with path=value pairs passed using the --set flag
+func (cfg *config) setConfigFromFlags(sets []string) error {
+	builtin := make([]string, 0, len(sets))
+	for _, set := range sets {
+		if strings.HasPrefix(set, appKey+".") {
+			// Applied when the app section is decoded
+			cfg.appSets = append(cfg.appSets, set)
+			continue
+		}
+		builtin = append(builtin, set)
+	}
+
+	return applyFlagOverrides(cfg.overrideFields(), builtin)
+}
+
+func applyEnvOverrides(fields []*overrideField, environ []string) error {
+	env := make(map[string]string, len(environ))
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, EnvPrefix) {
+			continue
+		}
+		if i := strings.Index(kv, "="); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	if len(env) == 0 {
+		return nil
+	}
+
+	for _, field := range fields {
+		value, ok := env[field.envName()]
+		if !ok {
+			continue
+		}
+		err := field.set(value)
+		if err != nil {
+			return errors.Wrapf(err, "failed to set config from env %s", field.envName())
+		}
+	}
+
+	return nil
+}
+
+func applyFlagOverrides(fields []*overrideField, sets []string) error {
+	if len(sets) == 0 {
+		return nil
+	}
+
+	fieldsByName := make(map[string]*overrideField)
+	for _, field := range fields {
+		fieldsByName[field.flagName()] = field
+	}
+
+	for _, set := range sets {
+		i := strings.Index(set, "=")
+		if i <= 0 {
+			return fmt.Errorf("invalid --set value %q, expected path=value", set)
+		}
+
+		path, value := strings.TrimSpace(set[:i]), set[i+1:]
+
+		field, ok := fieldsByName[path]
+		if !ok {
+			return fmt.Errorf("invalid --set value %q, unknown config path %s", set, path)
+		}
+
+		err := field.set(value)
+		if err != nil {
+			return errors.Wrapf(err, "failed to set config from flag --set %s", path)
+		}
+	}
+
+	return nil
+}
+
+// setFlags collects values of a repeated --set flag
+type setFlags []string
+
+func (s *setFlags) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *setFlags) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"testing"
+)
+
+func newOverrideTestConfig() *config {
+	cfg := newConfig()
+	cfg.HTTPort = 8080
+	cfg.Databases = []*databaseOptions{
+		{Type: SQLDBType, Address: "localhost:3306", Metadata: &dbMetadata{Name: "orders"}},
+		{Type: RedisDBType, Address: "localhost:6379", Metadata: &dbMetadata{Name: "cache-db"}},
+	}
+	cfg.ExternalServices = []*externalServiceOptions{
+		{Name: "payments", Address: "payments:443"},
+	}
+	return cfg
+}
+
+func TestSetConfigFromEnv(t *testing.T) {
+	cfg := newOverrideTestConfig()
+
+	err := cfg.setConfigFromEnv([]string{
+		"MICRO_HTTP_PORT=9090",
+		"MICRO_LOG_LEVEL=2",
+		"MICRO_HTTP_OPTIONS_CORS_ENABLED=true",
+		"MICRO_SECURITY_CIPHER_SUITES=a, b",
+		"MICRO_DATABASES_ORDERS_ADDRESS=orders-db:3306",
+		"MICRO_DATABASES_CACHE_DB_POOL_SETTINGS_MAX_OPEN_CONNS=10",
+		"MICRO_EXTERNAL_SERVICES_0_INSECURE=true",
+		"MICRO_UNKNOWN=ignored",
+		"OTHER=ignored",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	switch {
+	case cfg.HTTPort != 9090:
+		t.Errorf("httpPort = %d, want 9090", cfg.HTTPort)
+	case cfg.LogLevel != 2:
+		t.Errorf("logLevel = %d, want 2", cfg.LogLevel)
+	case !cfg.HttpOtions.CorsEnabled:
+		t.Error("httpOptions.corsEnabled not set")
+	case len(cfg.Security.CipherSuites) != 2:
+		t.Errorf("security.cipherSuites = %v, want [a b]", cfg.Security.CipherSuites)
+	case cfg.Databases[0].Address != "orders-db:3306":
+		t.Errorf("databases.orders.address = %s, want orders-db:3306", cfg.Databases[0].Address)
+	case cfg.Databases[1].PoolSettings == nil || cfg.Databases[1].PoolSettings.MaxOpenConns != 10:
+		t.Error("databases.cache-db.poolSettings.maxOpenConns not set")
+	case !cfg.ExternalServices[0].Insecure:
+		t.Error("externalServices.0.insecure not set")
+	}
+
+	err = cfg.setConfigFromEnv([]string{"MICRO_GRPC_PORT=abc"})
+	if err == nil {
+		t.Error("expected error for invalid integer")
+	}
+}
+
+func TestSetConfigFromFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		sets    []string
+		wantErr bool
+		check   func(cfg *config) bool
+	}{
+		{
+			name:  "top level field",
+			sets:  []string{"httpPort=7070"},
+			check: func(cfg *config) bool { return cfg.HTTPort == 7070 },
+		},
+		{
+			name:  "entry by name",
+			sets:  []string{"databases.orders.address=db:3306"},
+			check: func(cfg *config) bool { return cfg.Databases[0].Address == "db:3306" },
+		},
+		{
+			name:  "later flag wins",
+			sets:  []string{"externalServices.payments.address=a", "externalServices.payments.address=b"},
+			check: func(cfg *config) bool { return cfg.ExternalServices[0].Address == "b" },
+		},
+		{
+			name:    "unknown path",
+			sets:    []string{"databases.missing.address=db:3306"},
+			wantErr: true,
+		},
+		{
+			name:    "missing value separator",
+			sets:    []string{"httpPort"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newOverrideTestConfig()
+
+			err := cfg.setConfigFromFlags(tt.sets)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("setConfigFromFlags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.check != nil && !tt.check(cfg) {
+				t.Error("config value was not overridden")
+			}
+		})
+	}
+}
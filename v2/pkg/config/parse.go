@@ -3,21 +3,66 @@ package config
 import (
 	"flag"
 	"fmt"
+	"io/fs"
+	"os"
+	"strings"
 )
 
-// parses config parameters either from environment variables, files or cmd flags
-func (cfg *config) parse(cf ...string) error {
-	var err error
+// source contains the inputs config is read from, kept for reloading
+type source struct {
+	file     string
+	profiles []string
+	sets     []string
+	// fsys is the file system of the config files, nil for the OS file system
+	fsys fs.FS
+	// content of the config file when it is read from an io.Reader
+	content []byte
+}
+
+// BindFlags defines --config-file, --profile and repeatable --set flags on flagSet that set the options when parsed.
+// Use pflag.FlagSet.AddGoFlagSet to add them to a pflag set.
+func (opt *Options) BindFlags(flagSet *flag.FlagSet) {
+	if opt.File == "" {
+		opt.File = "configs/config.yml"
+	}
 
-	configFile := flag.String(
-		"config-file", "configs/config.yml",
-		`File location to read config parameter`,
+	flagSet.StringVar(&opt.File, "config-file", opt.File, `File location to read config parameter`)
+
+	flagSet.Var(
+		(*profileFlag)(&opt.Profiles), "profile",
+		`Comma separated config profiles overlaid in order on the config file e.g staging reads config.staging.yml. Defaults to `+EnvProfile+` env`,
 	)
 
-	flag.Parse()
+	flagSet.Var((*setFlags)(&opt.Sets), "set", `Override config value using its yaml path e.g --set databases.orders.address=localhost:3306 (repeatable)`)
+}
+
+// profileFlag sets profiles from a comma separated list
+type profileFlag []string
 
+func (p *profileFlag) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *profileFlag) Set(value string) error {
+	*p = splitProfiles(value)
+	return nil
+}
+
+// load reads config from its source
+func (cfg *config) load() error {
 	// Update config
-	err = cfg.setConfigFromFile(firstVal(append(cf, *configFile)...))
+	err := cfg.setConfigFromFile(cfg.source)
+	if err != nil {
+		return err
+	}
+
+	// Override config from env then flags
+	err = cfg.setConfigFromEnv(os.Environ())
+	if err != nil {
+		return err
+	}
+
+	err = cfg.setConfigFromFlags(cfg.source.sets)
 	if err != nil {
 		return err
 	}
@@ -31,6 +76,19 @@ func (cfg *config) parse(cf ...string) error {
 	return nil
 }
 
+// EnvProfile is the environment variable listing config profiles when the --profile flag is not set
+const EnvProfile = "MICRO_PROFILE"
+
+func splitProfiles(profiles string) []string {
+	vals := make([]string, 0)
+	for _, profile := range strings.Split(profiles, ",") {
+		if profile = strings.TrimSpace(profile); profile != "" {
+			vals = append(vals, profile)
+		}
+	}
+	return vals
+}
+
 func firstVal(vs ...string) string {
 	for _, v := range vs {
 		if v != "" {
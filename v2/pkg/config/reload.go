@@ -0,0 +1,245 @@
+package config
+
+import (
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// liveConfig holds the current config snapshot. Snapshots are never modified, reloading replaces them.
+type liveConfig struct {
+	mu          sync.RWMutex
+	current     *config
+	subscribers []func(old, new *Config)
+	reloadMu    sync.Mutex
+	// last config read from files, used to only report changes once
+	loaded *config
+}
+
+func newLiveConfig(cfg *config) *Config {
+	return &Config{
+		config: *cfg,
+		live:   &liveConfig{current: cfg, loaded: cfg},
+	}
+}
+
+// snapshot returns the current config
+func (cfg *Config) snapshot() *config {
+	if cfg.live == nil {
+		return &cfg.config
+	}
+	cfg.live.mu.RLock()
+	defer cfg.live.mu.RUnlock()
+	return cfg.live.current
+}
+
+// Subscribe registers fn to be called with the previous and the new config after the config is reloaded
+func (cfg *Config) Subscribe(fn func(old, new *Config)) {
+	if cfg.live == nil || fn == nil {
+		return
+	}
+	cfg.live.mu.Lock()
+	defer cfg.live.mu.Unlock()
+	cfg.live.subscribers = append(cfg.live.subscribers, fn)
+}
+
+// ReloadResult describes the outcome of reloading config
+type ReloadResult struct {
+	// Changed is true when reloadable fields changed and subscribers were notified
+	Changed bool
+	// Ignored are yaml paths of fields that changed but cannot be reloaded, they keep their current values
+	Ignored []string
+}
+
+// Reload reads the config file, profiles and secret files again and validates the result.
+// Log level, CORS, database pool settings and the app section are updated and subscribers notified.
+// Changes to other fields require a restart and are reported in the result. The current config
+// remains in use if the new config is invalid.
+func (cfg *Config) Reload() (*ReloadResult, error) {
+	if cfg.live == nil || cfg.snapshot().source == nil {
+		return nil, errors.New("config was not loaded from a file")
+	}
+
+	cfg.live.reloadMu.Lock()
+	defer cfg.live.reloadMu.Unlock()
+
+	old := cfg.snapshot()
+
+	loaded := newConfig()
+	loaded.source = old.source
+
+	err := loaded.load()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to reload config")
+	}
+
+	err = loaded.validate()
+	if err != nil {
+		return nil, errors.Wrap(err, "validation error")
+	}
+
+	if reflect.DeepEqual(loaded, cfg.live.loaded) {
+		return &ReloadResult{}, nil
+	}
+	cfg.live.loaded = loaded
+
+	next, ignored := reloadable(old, loaded)
+
+	res := &ReloadResult{
+		Changed: !reflect.DeepEqual(old, next),
+		Ignored: ignored,
+	}
+
+	if !res.Changed {
+		return res, nil
+	}
+
+	cfg.live.mu.Lock()
+	cfg.live.current = next
+	subscribers := append([]func(old, new *Config){}, cfg.live.subscribers...)
+	cfg.live.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(&Config{config: *old}, &Config{config: *next})
+	}
+
+	return res, nil
+}
+
+// reloadable returns old updated with the reloadable fields of loaded,
+// along with the paths of fields that changed but cannot be reloaded
+func reloadable(old, loaded *config) (*config, []string) {
+	next := *old
+	next.LogLevel = loaded.LogLevel
+	next.App = loaded.App
+	next.environ = loaded.environ
+	next.appSets = loaded.appSets
+
+	httpOpts := *old.HttpOtions
+	httpOpts.CorsEnabled = loaded.HttpOtions.CorsEnabled
+	next.HttpOtions = &httpOpts
+
+	// Pool settings are updated for databases that did not change otherwise
+	next.Databases = make([]*databaseOptions, 0, len(old.Databases))
+	for i, db := range old.Databases {
+		if i < len(loaded.Databases) {
+			updated := *db
+			updated.PoolSettings = loaded.Databases[i].PoolSettings
+			if reflect.DeepEqual(&updated, loaded.Databases[i]) {
+				db = &updated
+			}
+		}
+		next.Databases = append(next.Databases, db)
+	}
+
+	ignored := make([]string, 0)
+
+	nv, lv := reflect.ValueOf(next), reflect.ValueOf(*loaded)
+	for i := 0; i < nv.NumField(); i++ {
+		field := nv.Type().Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		if !reflect.DeepEqual(nv.Field(i).Interface(), lv.Field(i).Interface()) {
+			ignored = append(ignored, yamlName(field))
+		}
+	}
+
+	return &next, ignored
+}
+
+// WatchOptions contains options for reloading config when its files change
+type WatchOptions struct {
+	// Interval is how often the files are checked for changes. Zero disables polling, SIGHUP always triggers a reload.
+	Interval time.Duration
+	// OnError is called when reloading fails, the current config remains in use. Errors are logged by default.
+	OnError func(error)
+	// OnIgnored is called with yaml paths of changed fields that cannot be reloaded without a restart.
+	// Ignored fields are logged as a warning by default.
+	OnIgnored func(fields []string)
+}
+
+// Watcher reloads config when its files change or the process receives SIGHUP
+type Watcher struct {
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// Watch starts reloading config when its files change or the process receives SIGHUP. See Reload.
+func (cfg *Config) Watch(opt *WatchOptions) (*Watcher, error) {
+	if opt == nil {
+		opt = &WatchOptions{}
+	}
+	onError, onIgnored := opt.OnError, opt.OnIgnored
+	if onError == nil {
+		onError = func(err error) {
+			log.Printf("ERROR: config: %v", err)
+		}
+	}
+	if onIgnored == nil {
+		onIgnored = func(fields []string) {
+			log.Printf("WARN: config: changes to %s require a restart and were ignored", strings.Join(fields, ", "))
+		}
+	}
+	if cfg.live == nil || cfg.snapshot().source == nil {
+		return nil, errors.New("config was not loaded from a file")
+	}
+
+	watcher := &Watcher{stop: make(chan struct{})}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGHUP)
+
+	go func() {
+		defer signal.Stop(sigChan)
+
+		var tick <-chan time.Time
+		if opt.Interval > 0 {
+			ticker := time.NewTicker(opt.Interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+
+		// Errors are reported once until the files change
+		var lastErr string
+
+		for {
+			select {
+			case <-watcher.stop:
+				return
+			case <-sigChan:
+			case <-tick:
+			}
+
+			res, err := cfg.Reload()
+			switch {
+			case err != nil:
+				if err.Error() != lastErr {
+					onError(err)
+				}
+				lastErr = err.Error()
+				continue
+			case len(res.Ignored) > 0:
+				onIgnored(res.Ignored)
+			}
+			lastErr = ""
+		}
+	}()
+
+	return watcher, nil
+}
+
+// Close stops watching config for changes
+func (watcher *Watcher) Close() error {
+	watcher.stopOnce.Do(func() {
+		close(watcher.stop)
+	})
+	return nil
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.yml")
+
+	write := func(content string) {
+		err := ioutil.WriteFile(filename, []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("serviceName: orders\nhttpPort: 8080\ngrpcPort: 8081\nlogLevel: 1\nsecurity:\n  insecure: true\n")
+
+	loaded := newConfig()
+	loaded.source = &source{file: filename}
+	err = loaded.load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := newLiveConfig(loaded)
+
+	var oldLevel, newLevel int
+	cfg.Subscribe(func(old, new *Config) {
+		oldLevel, newLevel = old.LogLevel(), new.LogLevel()
+	})
+
+	write("serviceName: orders\nhttpPort: 9090\ngrpcPort: 8081\nlogLevel: 3\nhttpOptions:\n  corsEnabled: true\nsecurity:\n  insecure: true\n")
+
+	res, err := cfg.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	switch {
+	case !res.Changed:
+		t.Error("Reload() did not report changes")
+	case !reflect.DeepEqual(res.Ignored, []string{"httpPort"}):
+		t.Errorf("Reload() ignored = %v, want [httpPort]", res.Ignored)
+	case oldLevel != 1 || newLevel != 3:
+		t.Errorf("subscriber got log level %d -> %d, want 1 -> 3", oldLevel, newLevel)
+	case cfg.LogLevel() != 3 || !cfg.HttpOptions().CorsEnabled():
+		t.Error("reloadable fields not updated")
+	case cfg.ServicePort() != 8080:
+		t.Errorf("ServicePort() = %d, want 8080 until restart", cfg.ServicePort())
+	}
+
+	// Invalid config keeps the current one
+	write("httpPort: 9090\n")
+
+	_, err = cfg.Reload()
+	if err == nil {
+		t.Error("expected validation error")
+	}
+	if cfg.LogLevel() != 3 {
+		t.Errorf("LogLevel() = %d after failed reload, want 3", cfg.LogLevel())
+	}
+}
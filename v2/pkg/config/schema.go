@@ -0,0 +1,341 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gidyon/micro/v2/utils/tlsutil"
+)
+
+// jsonSchema is the subset of JSON Schema used to describe config files
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Minimum              *float64               `json:"minimum,omitempty"`
+	Maximum              *float64               `json:"maximum,omitempty"`
+	OneOf                []*jsonSchema          `json:"oneOf,omitempty"`
+	// scalars is set for string fields decoded by yaml, which accepts numbers and booleans as written
+	scalars bool
+}
+
+// schemaDescriptions describe config fields by their yaml path, list entries are denoted by []
+var schemaDescriptions = map[string]string{
+	"include":                               "Config files merged before this file, relative to its directory",
+	"serviceName":                           "Name of the service",
+	"serviceType":                           "Kubernetes service type",
+	"httpPort":                              "Port serving REST and, when tls or h2c is enabled, gRPC",
+	"grpcPort":                              "Port serving gRPC when tls and h2c are disabled, defaults to 8080",
+	"adminPort":                             "Port serving health, metrics and admin endpoints. Zero serves them on the service port",
+	"httpOptions":                           "Options for the http server",
+	"httpOptions.corsEnabled":               "Allow cross origin requests",
+	"httpOptions.h2cEnabled":                "Serve gRPC and REST on the same cleartext port using HTTP/2 without tls",
+	"startupSleepSeconds":                   "Seconds to wait before starting the service",
+	"shutdownDrainSeconds":                  "Seconds to wait after readiness starts failing on shutdown before the servers are stopped",
+	"logLevel":                              "Zerolog log level from -1 (trace) to 5 (panic), lower is more verbose. The service logs at trace level when unset",
+	"security":                              "TLS options for the service",
+	"security.tlsCert":                      "Path of the tls certificate",
+	"security.tlsKey":                       "Path of the tls private key",
+	"security.serverName":                   "Server name in the tls certificate",
+	"security.insecure":                     "Serve without tls",
+	"security.certReloadSeconds":            "How often the certificate and key are reloaded from disk. Zero disables reloading",
+	"security.minVersion":                   "Minimum tls version e.g 1.2 or 1.3, defaults to 1.0",
+	"security.cipherSuites":                 "Allowed cipher suites, defaults to Go defaults",
+	"security.clientCA":                     "Path of the CA bundle used to verify client certificates, defaults to the service certificate when clientAuth is unset",
+	"security.clientAuth":                   "Client certificate authentication mode, defaults to request",
+	"databases":                             "Databases used by the service, merged by metadata.name across files",
+	"databases[].required":                  "Fail startup if the database is not available",
+	"databases[].type":                      "Type of the database",
+	"databases[].address":                   "Network address of the database",
+	"databases[].user":                      "Database user",
+	"databases[].schema":                    "Database schema or number",
+	"databases[].password":                  "Database password",
+	"databases[].userFile":                  "File containing the database user",
+	"databases[].schemaFile":                "File containing the database schema",
+	"databases[].passwordFile":              "File containing the database password",
+	"databases[].poolSettings":              "Connection pool settings",
+	"databases[].poolSettings.maxOpenConns": "Maximum number of open connections",
+	"databases[].poolSettings.maxIdleConns": "Maximum number of idle connections",
+	"databases[].poolSettings.maxConnLifetimeSeconds": "Maximum lifetime of a connection in seconds",
+	"databases[].waitFor":                             "Options for waiting for the database at startup",
+	"databases[].metadata":                            "Database metadata",
+	"databases[].metadata.name":                       "Unique name of the database",
+	"databases[].metadata.dialect":                    "SQL dialect",
+	"databases[].metadata.orm":                        "ORM used to open the connection",
+	"databases[].metadata.useRediSearch":              "Create a RediSearch client for the redis database",
+	"databases[].metadata.sslMode":                    "Postgres ssl mode",
+	"databases[].metadata.searchPath":                 "Postgres schema search path",
+	"databases[].metadata.timeZone":                   "Time zone of postgres sessions e.g UTC",
+	"externalServices":                                "Services called by the service, merged by name across files",
+	"externalServices[].name":                         "Unique name of the service, compared case insensitively",
+	"externalServices[].required":                     "Fail startup if the service is not available",
+	"externalServices[].k8service":                    "The service runs in Kubernetes",
+	"externalServices[].address":                      "Network address of the service",
+	"externalServices[].tlsCert":                      "Path of the certificate used to verify the service",
+	"externalServices[].serverName":                   "Server name in the service tls certificate",
+	"externalServices[].insecure":                     "Dial the service without tls",
+	"externalServices[].waitFor":                      "Options for waiting for the service at startup",
+	"auth":                                            "Options for signing and verifying jwt tokens",
+	"auth.issuer":                                     "Issuer of tokens",
+	"auth.audience":                                   "Audience of tokens",
+	"auth.signingMethod":                              "JWT signing method, defaults to HS256",
+	"auth.signingKey":                                 "Key used to sign tokens",
+	"auth.signingKeyFile":                             "File containing the signing key",
+	"auth.otherSigningKeys":                           "Other keys accepted when verifying tokens e.g during key rotation",
+	"app":                                             "Application specific settings, see Config.DecodeApp",
+}
+
+// schemaEnums are the allowed values of config fields by their yaml path
+var schemaEnums = map[string][]string{
+	"databases[].type":             dbTypes,
+	"databases[].metadata.dialect": sqlDialects,
+	"databases[].metadata.sslMode": sslModes,
+	"security.clientAuth":          tlsutil.ClientAuthModes,
+	"auth.signingMethod":           signingMethods,
+}
+
+// portFields are yaml paths of ports
+var portFields = []string{"httpPort", "grpcPort", "adminPort"}
+
+// JSONSchema returns a JSON Schema describing config files, e.g for editor support and validation in CI
+func JSONSchema() ([]byte, error) {
+	return json.MarshalIndent(configSchema(), "", "  ")
+}
+
+var (
+	schemaOnce   sync.Once
+	cachedSchema *jsonSchema
+)
+
+func configSchema() *jsonSchema {
+	schemaOnce.Do(func() {
+		cachedSchema = newConfigSchema()
+	})
+	return cachedSchema
+}
+
+func newConfigSchema() *jsonSchema {
+	schema := schemaFor(reflect.TypeOf(config{}), "")
+	schema.Schema = "http://json-schema.org/draft-07/schema#"
+	schema.Title = "Service config"
+	schema.Properties[includeKey] = &jsonSchema{
+		Description: schemaDescriptions[includeKey],
+		OneOf: []*jsonSchema{
+			{Type: "string"},
+			{Type: "array", Items: &jsonSchema{Type: "string"}},
+		},
+	}
+
+	return schema
+}
+
+func schemaFor(t reflect.Type, path string) *jsonSchema {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	schema := &jsonSchema{
+		Description: schemaDescriptions[path],
+		Enum:        schemaEnums[path],
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		schema.Type = "string"
+		schema.scalars = true
+	case reflect.Bool:
+		schema.Type = "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		schema.Type = "integer"
+		if contains(portFields, path) {
+			min, max := float64(0), float64(maxPort)
+			schema.Minimum, schema.Maximum = &min, &max
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		schema.Type = "integer"
+		min := float64(0)
+		schema.Minimum = &min
+	case reflect.Float32, reflect.Float64:
+		schema.Type = "number"
+	case reflect.Slice, reflect.Array:
+		schema.Type = "array"
+		schema.Items = schemaFor(t.Elem(), path+"[]")
+	case reflect.Map:
+		schema.Type = "object"
+	case reflect.Struct:
+		additional := false
+		schema.Type = "object"
+		schema.AdditionalProperties = &additional
+		schema.Properties = make(map[string]*jsonSchema, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := yamlName(field)
+			if field.PkgPath != "" || name == "-" {
+				continue
+			}
+			schema.Properties[name] = schemaFor(field.Type, joinPath(path, name))
+		}
+	}
+
+	return schema
+}
+
+// property returns the schema of a field of an object, or nil if the schema or field is unknown
+func (schema *jsonSchema) property(key string) *jsonSchema {
+	if schema == nil {
+		return nil
+	}
+	return schema.Properties[key]
+}
+
+// items returns the schema of the elements of an array, or nil if the schema is unknown
+func (schema *jsonSchema) items() *jsonSchema {
+	if schema == nil {
+		return nil
+	}
+	return schema.Items
+}
+
+// validateSchema validates yaml content against the config schema
+func validateSchema(content map[interface{}]interface{}) error {
+	v := &validator{}
+	configSchema().validate(v, "", content)
+	return v.err()
+}
+
+func (schema *jsonSchema) validate(v *validator, path string, val interface{}) {
+	// Null values leave fields unset
+	if val == nil {
+		return
+	}
+
+	if len(schema.OneOf) > 0 {
+		for _, s := range schema.OneOf {
+			sv := &validator{}
+			s.validate(sv, path, val)
+			if sv.err() == nil {
+				return
+			}
+		}
+		types := make([]string, 0, len(schema.OneOf))
+		for _, s := range schema.OneOf {
+			types = append(types, s.Type)
+		}
+		v.addf(path, "expected %s, got %s", strings.Join(types, " or "), yamlType(val))
+		return
+	}
+
+	switch schema.Type {
+	case "object":
+		m, ok := val.(map[interface{}]interface{})
+		if !ok {
+			v.addf(path, "expected object, got %s", yamlType(val))
+			return
+		}
+		if schema.Properties == nil {
+			return
+		}
+
+		keys := make([]string, 0, len(m))
+		for key := range m {
+			keys = append(keys, fmt.Sprint(key))
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			keyPath := joinPath(path, key)
+			prop, ok := schema.Properties[key]
+			if !ok {
+				v.addf(keyPath, "unknown field%s", suggestField(key, schema.Properties))
+				continue
+			}
+			prop.validate(v, keyPath, m[key])
+		}
+
+	case "array":
+		list, ok := val.([]interface{})
+		if !ok {
+			v.addf(path, "expected array, got %s", yamlType(val))
+			return
+		}
+		for i, elem := range list {
+			schema.Items.validate(v, fmt.Sprintf("%s[%d]", path, i), elem)
+		}
+
+	case "string":
+		// Scalars such as schema: 0 or password: 12345 are decoded into string fields as written
+		var s string
+		switch val := val.(type) {
+		case string:
+			s = val
+		case bool, int, int64, uint64, float64:
+			if !schema.scalars {
+				v.addf(path, "expected string, got %s", yamlType(val))
+				return
+			}
+			s = fmt.Sprint(val)
+		default:
+			v.addf(path, "expected string, got %s", yamlType(val))
+			return
+		}
+		if len(schema.Enum) > 0 && s != "" && !contains(schema.Enum, s) {
+			v.addf(path, "value %q must be one of %v", s, schema.Enum)
+		}
+
+	case "boolean":
+		if _, ok := val.(bool); !ok {
+			v.addf(path, "expected boolean, got %s", yamlType(val))
+		}
+
+	case "integer", "number":
+		n, isLen, ok := numericValue(reflect.ValueOf(val))
+		if !ok || isLen || (schema.Type == "integer" && n != math.Trunc(n)) {
+			v.addf(path, "expected %s, got %s", schema.Type, yamlType(val))
+			return
+		}
+		if schema.Minimum != nil && n < *schema.Minimum {
+			v.addf(path, "value %v must be at least %v", val, *schema.Minimum)
+		}
+		if schema.Maximum != nil && n > *schema.Maximum {
+			v.addf(path, "value %v must be at most %v", val, *schema.Maximum)
+		}
+	}
+}
+
+// suggestField returns a hint for a misspelled field whose name differs only in case
+func suggestField(key string, props map[string]*jsonSchema) string {
+	for name := range props {
+		if strings.EqualFold(name, key) {
+			return fmt.Sprintf(", did you mean %s?", name)
+		}
+	}
+	return ""
+}
+
+// yamlType returns the type of a decoded value for errors, values are left out since they may be secrets
+func yamlType(val interface{}) string {
+	switch val.(type) {
+	case map[interface{}]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case int, int64, uint64, float64:
+		return "number"
+	default:
+		return fmt.Sprintf("%T", val)
+	}
+}
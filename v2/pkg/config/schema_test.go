@@ -0,0 +1,154 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestJSONSchema(t *testing.T) {
+	bs, err := JSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	schema := &jsonSchema{}
+	err = json.Unmarshal(bs, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbType := schema.Properties["databases"].Items.Properties["type"]
+	if !reflect.DeepEqual(dbType.Enum, []string{SQLDBType, RedisDBType}) {
+		t.Errorf("databases[].type enum = %v", dbType.Enum)
+	}
+
+	pool := schema.Properties["databases"].Items.Properties["poolSettings"]
+	if pool.Properties["maxOpenConns"].Type != "integer" {
+		t.Errorf("databases[].poolSettings.maxOpenConns type = %q, want integer", pool.Properties["maxOpenConns"].Type)
+	}
+
+	if schema.Properties["app"].AdditionalProperties != nil {
+		t.Error("app section must allow any fields")
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "valid",
+			content: "include: [base.yml]\nserviceName: orders\nhttpPort: 8080\nlogLevel: -1\nsecurity:\n  insecure: true\ndatabases:\n  - type: sqlDatabase\n    poolSettings:\n      maxOpenConns: 10\napp:\n  anything: [1, 2]\n",
+		},
+		{
+			name:    "scalars in string fields",
+			content: "serviceName: orders\ndatabases:\n  - type: sqlDatabase\n    schema: 0\n    password: 12345\n    user: true\n",
+		},
+		{
+			name: "invalid",
+			content: `
+include: 10
+serviceName: [orders]
+httpPort: 70000
+grpcport: 8081
+security:
+  insecure: "yes please"
+databases:
+  - type: sql
+    poolSettings:
+      maxOpenConns: -1
+    metadata:
+      dialect: oracle
+externalServices: payments
+`,
+			want: []string{
+				"include",
+				"serviceName",
+				"httpPort",
+				"grpcport",
+				"security.insecure",
+				"databases[0].type",
+				"databases[0].poolSettings.maxOpenConns",
+				"databases[0].metadata.dialect",
+				"externalServices",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := make(map[interface{}]interface{})
+			err := yaml.Unmarshal([]byte(tt.content), &content)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = validateSchema(content)
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("validateSchema() error = %v", err)
+				}
+				return
+			}
+
+			validationErr := &ValidationError{}
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("validateSchema() error = %v, want *ValidationError", err)
+			}
+
+			paths := make([]string, 0, len(validationErr.Errors))
+			for _, fieldErr := range validationErr.Errors {
+				paths = append(paths, fieldErr.Path)
+			}
+
+			sort.Strings(paths)
+			sort.Strings(tt.want)
+
+			if !reflect.DeepEqual(paths, tt.want) {
+				t.Errorf("validateSchema() paths = %v, want %v\nerror: %v", paths, tt.want, err)
+			}
+		})
+	}
+}
+
+func TestValidateSchemaHidesValues(t *testing.T) {
+	content := make(map[interface{}]interface{})
+	err := yaml.Unmarshal([]byte("auth:\n  signingKey: [s3cret]\nhttpPort: s3cret\n"), &content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = validateSchema(content)
+	if err == nil {
+		t.Fatal("validateSchema() error = nil")
+	}
+	if strings.Contains(err.Error(), "s3cret") {
+		t.Errorf("validateSchema() error = %v, want values left out", err)
+	}
+}
+
+func TestLoadScalarStringFields(t *testing.T) {
+	content := "serviceName: orders\nhttpPort: 8080\nsecurity:\n  insecure: true\n" +
+		"databases:\n  - type: sqlDatabase\n    address: localhost:3306\n    user: orders\n    schema: 0\n    password: 12345\n    metadata:\n      name: orders\n"
+
+	cfg, err := Load(&Options{File: "config.yml", Reader: strings.NewReader(content)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := cfg.SQLDatabaseByName("orders")
+	if db == nil {
+		t.Fatal("database orders not found")
+	}
+	if db.Schema() != "0" || db.Password() != "12345" {
+		t.Errorf("schema = %q, password = %q, want 0 and 12345", db.Schema(), db.Password())
+	}
+}
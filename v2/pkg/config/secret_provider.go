@@ -0,0 +1,285 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gidyon/micro/v2/utils/encryption"
+	"github.com/pkg/errors"
+)
+
+// SecretPrefix marks a config value resolved by a secret provider, e.g password: secret://env/DB_PASSWORD.
+// The first path segment is the name of the provider, the rest is passed to the provider.
+const SecretPrefix = "secret://"
+
+// SecretProvider resolves secrets referenced in config
+type SecretProvider interface {
+	GetSecret(ctx context.Context, path string) (string, error)
+}
+
+// SecretProviderFunc is a function that implements SecretProvider
+type SecretProviderFunc func(ctx context.Context, path string) (string, error)
+
+// GetSecret calls fn(ctx, path)
+func (fn SecretProviderFunc) GetSecret(ctx context.Context, path string) (string, error) {
+	return fn(ctx, path)
+}
+
+// SecretProviderOptions contains options for registering a secret provider
+type SecretProviderOptions struct {
+	// Name is the first segment of secret references resolved by the provider
+	Name     string
+	Provider SecretProvider
+	// CacheTTL is how long a secret is reused before it is fetched again. Zero caches secrets until RefreshSecrets is called
+	CacheTTL time.Duration
+	// Timeout for fetching a secret, defaults to 10 seconds
+	Timeout time.Duration
+}
+
+const defaultSecretTimeout = 10 * time.Second
+
+// DefaultSecretDir is the directory the built-in file provider reads secrets from, where Kubernetes mounts secrets
+// e.g secret://file/db/password reads /var/run/secrets/db/password
+const DefaultSecretDir = "/var/run/secrets"
+
+type cachedSecret struct {
+	value   string
+	expires time.Time
+}
+
+type secretProvider struct {
+	*SecretProviderOptions
+	mu    sync.Mutex
+	cache map[string]*cachedSecret
+}
+
+func (p *secretProvider) getSecret(path string) (string, error) {
+	p.mu.Lock()
+	cached, ok := p.cache[path]
+	p.mu.Unlock()
+
+	if ok && (p.CacheTTL <= 0 || time.Now().Before(cached.expires)) {
+		return cached.value, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.Timeout)
+	defer cancel()
+
+	val, err := p.Provider.GetSecret(ctx, path)
+	if err != nil {
+		return "", err
+	}
+
+	p.mu.Lock()
+	p.cache[path] = &cachedSecret{value: val, expires: time.Now().Add(p.CacheTTL)}
+	p.mu.Unlock()
+
+	return val, nil
+}
+
+func (p *secretProvider) refresh() {
+	p.mu.Lock()
+	p.cache = make(map[string]*cachedSecret)
+	p.mu.Unlock()
+}
+
+var secretProviders = struct {
+	mu        sync.RWMutex
+	providers map[string]*secretProvider
+}{providers: make(map[string]*secretProvider)}
+
+func init() {
+	// Built-in providers, registering another provider with the same name replaces them
+	for _, opt := range []*SecretProviderOptions{
+		{Name: "file", Provider: &FileSecretProvider{Dir: DefaultSecretDir}},
+		{Name: "env", Provider: EnvSecretProvider{}},
+	} {
+		err := RegisterSecretProvider(opt)
+		if err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterSecretProvider registers a provider for resolving secret://<name>/<path> references.
+// Providers should be registered at startup before config is created. A provider registered
+// with the name of an existing provider replaces it.
+//
+// The file and env providers are registered by default, the file provider reads files in DefaultSecretDir.
+func RegisterSecretProvider(opt *SecretProviderOptions) error {
+	switch {
+	case opt == nil || opt.Provider == nil:
+		return errors.New("nil secret provider not allowed")
+	case opt.Name == "":
+		return errors.New("missing secret provider name")
+	case strings.Contains(opt.Name, "/"):
+		return fmt.Errorf("secret provider name %q must not contain /", opt.Name)
+	}
+
+	copied := *opt
+	if copied.Timeout <= 0 {
+		copied.Timeout = defaultSecretTimeout
+	}
+
+	secretProviders.mu.Lock()
+	defer secretProviders.mu.Unlock()
+
+	secretProviders.providers[opt.Name] = &secretProvider{
+		SecretProviderOptions: &copied,
+		cache:                 make(map[string]*cachedSecret),
+	}
+
+	return nil
+}
+
+// UnregisterSecretProvider removes the provider registered with name, including the built-in providers.
+// It reports whether a provider was removed.
+func UnregisterSecretProvider(name string) bool {
+	secretProviders.mu.Lock()
+	defer secretProviders.mu.Unlock()
+
+	_, ok := secretProviders.providers[name]
+	delete(secretProviders.providers, name)
+
+	return ok
+}
+
+// RefreshSecrets clears cached secrets so that they are fetched again the next time config is loaded or reloaded
+func RefreshSecrets() {
+	secretProviders.mu.RLock()
+	defer secretProviders.mu.RUnlock()
+
+	for _, p := range secretProviders.providers {
+		p.refresh()
+	}
+}
+
+// ResolveSecret returns the secret referenced by ref which must have the secret:// prefix
+func ResolveSecret(ref string) (string, error) {
+	if !strings.HasPrefix(ref, SecretPrefix) {
+		return "", fmt.Errorf("secret reference %q must start with %s", ref, SecretPrefix)
+	}
+
+	ss := strings.SplitN(strings.TrimPrefix(ref, SecretPrefix), "/", 2)
+	if len(ss) != 2 || ss[1] == "" {
+		return "", fmt.Errorf("secret reference %q must have the form %s<provider>/<path>", ref, SecretPrefix)
+	}
+
+	secretProviders.mu.RLock()
+	p, ok := secretProviders.providers[ss[0]]
+	secretProviders.mu.RUnlock()
+
+	if !ok {
+		return "", fmt.Errorf("no secret provider registered with name %q", ss[0])
+	}
+
+	val, err := p.getSecret(ss[1])
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to get secret from provider %s", ss[0])
+	}
+
+	return val, nil
+}
+
+// FileSecretProvider reads secrets from files in a directory e.g secret://file/db/password
+type FileSecretProvider struct {
+	// Dir is the directory that secret paths are relative to, files outside it cannot be read
+	Dir string
+}
+
+// GetSecret reads the secret from the file at path with surrounding whitespace removed
+func (p *FileSecretProvider) GetSecret(_ context.Context, path string) (string, error) {
+	name, err := secretFilename(p.Dir, path)
+	if err != nil {
+		return "", err
+	}
+	return readSecretFile(name)
+}
+
+// secretFilename returns the file at path in dir, paths leaving dir are rejected
+func secretFilename(dir, path string) (string, error) {
+	if dir == "" {
+		return "", errors.New("missing secrets directory")
+	}
+	name := filepath.Join(dir, filepath.FromSlash(path))
+	rel, err := filepath.Rel(dir, name)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("secret path %s is outside %s", path, dir)
+	}
+	return name, nil
+}
+
+// EnvSecretProvider reads secrets from environment variables e.g secret://env/DB_PASSWORD
+type EnvSecretProvider struct{}
+
+// GetSecret returns the value of the environment variable named path
+func (EnvSecretProvider) GetSecret(_ context.Context, path string) (string, error) {
+	val, ok := os.LookupEnv(path)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s not set", path)
+	}
+	return val, nil
+}
+
+// EncryptedFileSecretProvider reads secrets from files in a directory encrypted using utils/encryption
+type EncryptedFileSecretProvider struct {
+	dir string
+	api encryption.API
+}
+
+// NewEncryptedFileSecretProvider creates a provider that decrypts files in dir with key.
+// Key must be at least 16 bytes long.
+func NewEncryptedFileSecretProvider(dir string, key []byte) (*EncryptedFileSecretProvider, error) {
+	api, err := encryption.NewAPI(key)
+	if err != nil {
+		return nil, err
+	}
+	return &EncryptedFileSecretProvider{dir: dir, api: api}, nil
+}
+
+// GetSecret decrypts the file at path with surrounding whitespace removed from the secret
+func (p *EncryptedFileSecretProvider) GetSecret(_ context.Context, path string) (string, error) {
+	name, err := secretFilename(p.dir, path)
+	if err != nil {
+		return "", err
+	}
+
+	bs, err := ioutil.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := p.api.Decrypt(bs)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to decrypt %s", path)
+	}
+
+	return string(bytes.TrimSpace(secret)), nil
+}
+
+// WriteSecret encrypts secret and writes it to the file at path
+func (p *EncryptedFileSecretProvider) WriteSecret(path, secret string) error {
+	bs, err := p.api.Encrypt([]byte(secret))
+	if err != nil {
+		return errors.Wrap(err, "failed to encrypt secret")
+	}
+
+	filename, err := secretFilename(p.dir, path)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(filename), 0700)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, bs, 0600)
+}
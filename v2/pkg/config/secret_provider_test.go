@@ -0,0 +1,154 @@
+package config
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestResolveSecret(t *testing.T) {
+	calls := 0
+	err := RegisterSecretProvider(&SecretProviderOptions{
+		Name: "test",
+		Provider: SecretProviderFunc(func(ctx context.Context, path string) (string, error) {
+			calls++
+			return strings.ToUpper(path), nil
+		}),
+		CacheTTL: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer UnregisterSecretProvider("test")
+
+	for i := 0; i < 2; i++ {
+		val, err := ResolveSecret("secret://test/db/password")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if val != "DB/PASSWORD" {
+			t.Errorf("ResolveSecret() = %q, want DB/PASSWORD", val)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("provider called %d times, want 1 with cache", calls)
+	}
+
+	RefreshSecrets()
+
+	_, err = ResolveSecret("secret://test/db/password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("provider called %d times, want 2 after refresh", calls)
+	}
+
+	// The built-in file provider cannot read files outside its directory
+	for _, ref := range []string{"secret://test", "secret://unknown/x", "file:///x", "secret://file/../../etc/hostname"} {
+		_, err = ResolveSecret(ref)
+		if err == nil {
+			t.Errorf("ResolveSecret(%q) expected error", ref)
+		}
+	}
+}
+
+func TestSecretProviders(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	encrypted, err := NewEncryptedFileSecretProvider(dir, []byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = encrypted.WriteSecret("jwt/signing-key", "jwt-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, opt := range []*SecretProviderOptions{
+		{Name: "vault", Provider: encrypted},
+		{Name: "files", Provider: &FileSecretProvider{Dir: dir}},
+	} {
+		err = RegisterSecretProvider(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer UnregisterSecretProvider(opt.Name)
+	}
+
+	err = ioutil.WriteFile(dir+"/redis-password", []byte("r3dis\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Setenv("CONFIG_TEST_DB_PASSWORD", "s3cret")
+	defer os.Unsetenv("CONFIG_TEST_DB_PASSWORD")
+
+	content := `
+databases:
+  - type: sqlDatabase
+    password: secret://env/CONFIG_TEST_DB_PASSWORD
+  - type: redisDatabase
+    password: secret://files/redis-password
+auth:
+  signingKey: secret://vault/jwt/signing-key
+`
+
+	cfg := newConfig()
+	err = yaml.UnmarshalStrict([]byte(content), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cfg.updateConfigSecrets()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	switch {
+	case cfg.Databases[0].Password != "s3cret":
+		t.Errorf("databases[0].password = %q from env provider", cfg.Databases[0].Password)
+	case cfg.Databases[1].Password != "r3dis":
+		t.Errorf("databases[1].password = %q from file provider", cfg.Databases[1].Password)
+	case cfg.Auth.SigningKey != "jwt-key":
+		t.Errorf("auth.signingKey = %q from encrypted file provider", cfg.Auth.SigningKey)
+	}
+}
+
+func TestUnregisterSecretProvider(t *testing.T) {
+	err := RegisterSecretProvider(&SecretProviderOptions{Name: "test", Provider: EnvSecretProvider{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !UnregisterSecretProvider("test") {
+		t.Error("UnregisterSecretProvider() = false for registered provider")
+	}
+	if UnregisterSecretProvider("test") {
+		t.Error("UnregisterSecretProvider() = true for removed provider")
+	}
+
+	_, err = ResolveSecret("secret://test/HOME")
+	if err == nil {
+		t.Error("ResolveSecret() expected error after provider was removed")
+	}
+}
+
+func TestFileSecretProviderDir(t *testing.T) {
+	p := &FileSecretProvider{Dir: "/var/run/secrets"}
+
+	for _, path := range []string{"../../etc/passwd", "db/../../../etc/passwd", ".."} {
+		_, err := p.GetSecret(context.Background(), path)
+		if err == nil || !strings.Contains(err.Error(), "outside") {
+			t.Errorf("GetSecret(%q) error = %v, want path outside directory error", path, err)
+		}
+	}
+}
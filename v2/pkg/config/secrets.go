@@ -2,53 +2,157 @@ package config
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
+	"reflect"
 	"strings"
 
 	"github.com/pkg/errors"
 )
 
+// FilePrefix marks a config value that is read from a file, e.g password: file:///var/run/secrets/db/password
+const FilePrefix = "file://"
+
+// updateConfigSecrets reads secrets from files so that they can come from mounted Kubernetes secrets.
+//
+// A string field named x is read from the file at xFile when xFile is set, e.g databases[0].passwordFile or
+// auth.signingKeyFile. Fields holding secrets are tagged secret:"true", their values prefixed with file:// are
+// replaced by the content of the file and values prefixed with secret:// are resolved by the registered
+// SecretProvider, see RegisterSecretProvider. Other fields are never resolved, e.g serviceName: file://x stays as is.
+// Fields holding file paths such as security.tlsKey or externalServices[0].tlsCert are tagged file:"path",
+// they accept file:// values which are used as paths.
+//
+// The yaml paths of resolved fields are recorded so that Dump redacts them.
 func (cfg *config) updateConfigSecrets() error {
-	var err error
+	cfg.secretPaths = make(map[string]bool)
+	return resolveSecrets(reflect.ValueOf(cfg).Elem(), "", cfg.secretPaths)
+}
+
+func resolveSecrets(v reflect.Value, path string, resolved map[string]bool) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return resolveSecrets(v.Elem(), path, resolved)
+
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			err := resolveSecrets(v.Index(i), fmt.Sprintf("%s[%d]", path, i), resolved)
+			if err != nil {
+				return err
+			}
+		}
+
+	case reflect.Struct:
+		t := v.Type()
 
-	// Update db secrets
-	for _, db := range cfg.Databases {
-		err = updateDatabaseSecret(db)
-		if err != nil {
-			return err
+		names := make([]string, 0, t.NumField())
+		fields := make(map[string]reflect.Value, t.NumField())
+		for i := 0; i < t.NumField(); i++ {
+			name := yamlName(t.Field(i))
+			if t.Field(i).PkgPath != "" || name == "-" {
+				continue
+			}
+			names = append(names, name)
+			fields[name] = v.Field(i)
+
+			var (
+				fieldPath = joinPath(path, name)
+				isPath    = t.Field(i).Tag.Get("file") == "path" || strings.HasSuffix(name, "File")
+				isSecret  = t.Field(i).Tag.Get("secret") == "true"
+				err       error
+			)
+
+			switch {
+			case v.Field(i).Kind() == reflect.String && (isPath || isSecret):
+				err = resolveSecretValue(v.Field(i), fieldPath, isPath, resolved)
+			case v.Field(i).Kind() == reflect.Slice && v.Field(i).Type().Elem().Kind() == reflect.String:
+				for j := 0; j < v.Field(i).Len() && isSecret && err == nil; j++ {
+					err = resolveSecretValue(v.Field(i).Index(j), fmt.Sprintf("%s[%d]", fieldPath, j), false, resolved)
+				}
+			default:
+				err = resolveSecrets(v.Field(i), fieldPath, resolved)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		// Fields named xFile hold the path of the file containing x
+		for _, name := range names {
+			fileField := fields[name]
+			target, ok := fields[strings.TrimSuffix(name, "File")]
+			if !strings.HasSuffix(name, "File") || !ok || fileField.Kind() != reflect.String || target.Kind() != reflect.String {
+				continue
+			}
+			if strings.TrimSpace(fileField.String()) == "" {
+				continue
+			}
+			val, err := readSecretFile(fileField.String())
+			if err != nil {
+				return errors.Wrapf(err, "failed to read %s from file", joinPath(path, strings.TrimSuffix(name, "File")))
+			}
+			target.SetString(val)
+			resolved[joinPath(path, strings.TrimSuffix(name, "File"))] = true
 		}
 	}
 
 	return nil
 }
 
-func updateDatabaseSecret(db *databaseOptions) error {
-	// user
-	if strings.TrimSpace(db.UserFile) != "" {
-		bs, err := ioutil.ReadFile(db.UserFile)
-		if err != nil {
-			return errors.Wrapf(err, "failed to read %s database username from file", db.Type)
-		}
-		db.User = string(bytes.TrimSpace(bs))
+func resolveSecretValue(v reflect.Value, path string, isPath bool, resolved map[string]bool) error {
+	if isPath {
+		v.SetString(strings.TrimPrefix(v.String(), FilePrefix))
+		return nil
 	}
 
-	// password
-	if strings.TrimSpace(db.PasswordFile) != "" {
-		bs, err := ioutil.ReadFile(db.PasswordFile)
-		if err != nil {
-			return errors.Wrapf(err, "failed to read %s database password from file", db.Type)
-		}
-		db.Password = string(bytes.TrimSpace(bs))
+	val, ok, err := readSecretValue(v.String())
+	if err != nil {
+		return errors.Wrapf(err, "failed to read secret %s", path)
 	}
-
-	// schema
-	if strings.TrimSpace(db.SchemaFile) != "" {
-		bs, err := ioutil.ReadFile(db.SchemaFile)
-		if err != nil {
-			return errors.Wrapf(err, "failed to read %s database schema from file", db.Type)
-		}
-		db.Schema = string(bytes.TrimSpace(bs))
+	if ok {
+		v.SetString(val)
+		resolved[path] = true
 	}
 
 	return nil
 }
+
+// readSecretValue returns the secret referenced by a value with the file:// or secret:// prefix.
+// It returns false if the value is not a reference.
+func readSecretValue(val string) (string, bool, error) {
+	var (
+		secret string
+		err    error
+	)
+
+	switch {
+	case strings.HasPrefix(val, FilePrefix):
+		secret, err = readSecretFile(strings.TrimPrefix(val, FilePrefix))
+	case strings.HasPrefix(val, SecretPrefix):
+		secret, err = ResolveSecret(val)
+	default:
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	return secret, true, nil
+}
+
+func readSecretFile(name string) (string, error) {
+	bs, err := ioutil.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSpace(bs)), nil
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
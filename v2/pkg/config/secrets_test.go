@@ -0,0 +1,96 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestUpdateConfigSecrets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	secrets := map[string]string{
+		"db-password":    "s3cret\n",
+		"redis-password": "r3dis",
+		"signing-key":    " jwt-key\n",
+		"previous-key":   "old-key",
+	}
+	for name, content := range secrets {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	content := strings.Replace(`
+serviceName: file://DIR/db-password
+security:
+  tlsKey: file://DIR/tls.key
+databases:
+  - type: sqlDatabase
+    passwordFile: DIR/db-password
+  - type: redisDatabase
+    password: file://DIR/redis-password
+externalServices:
+  - name: payments
+    address: secret://env/PAYMENTS_ADDRESS
+    tlsCert: file://DIR/payments.pem
+auth:
+  signingKeyFile: file://DIR/signing-key
+  otherSigningKeys:
+    - file://DIR/previous-key
+    - plain-key
+`, "DIR", dir, -1)
+
+	cfg := newConfig()
+	err = yaml.UnmarshalStrict([]byte(content), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cfg.updateConfigSecrets()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"serviceName", cfg.ServiceName, "file://" + dir + "/db-password"},
+		{"security.tlsKey", cfg.Security.TLSKeyFile, filepath.Join(dir, "tls.key")},
+		{"databases[0].password", cfg.Databases[0].Password, "s3cret"},
+		{"databases[1].password", cfg.Databases[1].Password, "r3dis"},
+		{"externalServices[0].address", cfg.ExternalServices[0].Address, "secret://env/PAYMENTS_ADDRESS"},
+		{"externalServices[0].tlsCert", cfg.ExternalServices[0].TLSCertFile, filepath.Join(dir, "payments.pem")},
+		{"auth.signingKey", cfg.Auth.SigningKey, "jwt-key"},
+		{"auth.otherSigningKeys[0]", cfg.Auth.OtherSigningKeys[0], "old-key"},
+		{"auth.otherSigningKeys[1]", cfg.Auth.OtherSigningKeys[1], "plain-key"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+
+	// Errors name the field that could not be read
+	cfg = newConfig()
+	err = yaml.UnmarshalStrict([]byte("databases:\n  - type: sqlDatabase\n    password: file://"+dir+"/missing\n"), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = cfg.updateConfigSecrets()
+	if err == nil || !strings.Contains(err.Error(), "databases[0].password") {
+		t.Errorf("updateConfigSecrets() error = %v, want error naming databases[0].password", err)
+	}
+}
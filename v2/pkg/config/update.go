@@ -6,7 +6,9 @@ func (cfg *config) updateConfigWith(newCfg *config) {
 	cfg.ServiceType = setStringIfEmpty(cfg.ServiceType, newCfg.ServiceType)
 	cfg.HTTPort = setIntIfZero(cfg.HTTPort, newCfg.HTTPort)
 	cfg.GRPCPort = setIntIfZero(cfg.GRPCPort, newCfg.GRPCPort)
+	cfg.AdminPort = setIntIfZero(cfg.AdminPort, newCfg.AdminPort)
 	cfg.StartupSleepSeconds = setIntIfZero(cfg.StartupSleepSeconds, newCfg.StartupSleepSeconds)
+	cfg.ShutdownDrainSeconds = setIntIfZero(cfg.ShutdownDrainSeconds, newCfg.ShutdownDrainSeconds)
 
 	// Service log
 	cfg.LogLevel = setLogLevl(cfg.LogLevel, newCfg.LogLevel)
@@ -17,10 +19,18 @@ func (cfg *config) updateConfigWith(newCfg *config) {
 		cfg.Security.TLSKeyFile = setStringIfEmpty(cfg.Security.TLSKeyFile, newCfg.Security.TLSKeyFile)
 		cfg.Security.ServerName = setStringIfEmpty(cfg.Security.ServerName, newCfg.Security.ServerName)
 		cfg.Security.Insecure = setBoolIfEmpty(cfg.Security.Insecure, newCfg.Security.Insecure)
+		cfg.Security.CertReloadSeconds = setIntIfZero(cfg.Security.CertReloadSeconds, newCfg.Security.CertReloadSeconds)
+		cfg.Security.MinVersion = setStringIfEmpty(cfg.Security.MinVersion, newCfg.Security.MinVersion)
+		cfg.Security.ClientCAFile = setStringIfEmpty(cfg.Security.ClientCAFile, newCfg.Security.ClientCAFile)
+		cfg.Security.ClientAuth = setStringIfEmpty(cfg.Security.ClientAuth, newCfg.Security.ClientAuth)
+		if len(newCfg.Security.CipherSuites) > 0 {
+			cfg.Security.CipherSuites = newCfg.Security.CipherSuites
+		}
 	}
 
 	if newCfg.HttpOtions != nil {
 		cfg.HttpOtions.CorsEnabled = setBoolIfEmpty(cfg.HttpOtions.CorsEnabled, newCfg.HttpOtions.CorsEnabled)
+		cfg.HttpOtions.H2CEnabled = setBoolIfEmpty(cfg.HttpOtions.H2CEnabled, newCfg.HttpOtions.H2CEnabled)
 	}
 
 	// Update databases options
@@ -33,6 +43,16 @@ func (cfg *config) updateConfigWith(newCfg *config) {
 		// cfg.ExternalServices
 		cfg.ExternalServices = newCfg.ExternalServices
 	}
+
+	// Auth section
+	if newCfg.Auth != nil {
+		cfg.Auth = newCfg.Auth
+	}
+
+	// Application specific section
+	if len(newCfg.App) != 0 {
+		cfg.App = newCfg.App
+	}
 }
 
 func setStringIfEmpty(def, val string) string {
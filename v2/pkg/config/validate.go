@@ -1,9 +1,12 @@
 package config
 
 import (
-	"errors"
+	"crypto/tls"
 	"fmt"
+	"os"
 	"strings"
+
+	"github.com/gidyon/micro/v2/utils/tlsutil"
 )
 
 const (
@@ -14,94 +17,293 @@ const (
 )
 
 var (
-	dbTypes = []string{SQLDBType, RedisDBType}
+	dbTypes     = []string{SQLDBType, RedisDBType}
+	sqlDialects = []string{"mysql", "postgres"}
+	sslModes    = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+	// signing keys are strings so only HMAC methods are supported
+	signingMethods = []string{"HS256", "HS384", "HS512"}
+)
+
+const (
+	maxPort              = 65535
+	defaultSigningMethod = "HS256"
 )
 
+// FieldError is a problem with a config field
+type FieldError struct {
+	// Path is the yaml path of the field e.g databases[2].address
+	Path    string
+	Message string
+	// Line is the line of the field in the config file, zero when unknown e.g for merged config
+	Line int
+}
+
+func (err *FieldError) Error() string {
+	if err.Line > 0 {
+		return fmt.Sprintf("line %d: %s: %s", err.Line, err.Path, err.Message)
+	}
+	return fmt.Sprintf("%s: %s", err.Path, err.Message)
+}
+
+// ValidationError contains every problem found when validating config
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+func (err *ValidationError) Error() string {
+	msgs := make([]string, 0, len(err.Errors))
+	for _, fieldErr := range err.Errors {
+		msgs = append(msgs, fieldErr.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// withLines sets the lines of field errors in err from lines, see fieldLines
+func withLines(err error, lines map[string]int) error {
+	if validationErr, ok := err.(*ValidationError); ok {
+		for _, fieldErr := range validationErr.Errors {
+			fieldErr.Line = lines[fieldErr.Path]
+		}
+	}
+	return err
+}
+
+// validator collects field errors
+type validator struct {
+	errs []*FieldError
+}
+
+func (v *validator) addf(path, format string, args ...interface{}) {
+	v.errs = append(v.errs, &FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) required(path, val string) bool {
+	if strings.TrimSpace(val) == "" {
+		v.addf(path, "value is required")
+		return false
+	}
+	return true
+}
+
+func (v *validator) port(path string, port int) {
+	if port < 0 || port > maxPort {
+		v.addf(path, "port %d out of range [0, %d]", port, maxPort)
+	}
+}
+
+func (v *validator) fileExists(path, name string) {
+	info, err := os.Stat(name)
+	switch {
+	case err != nil:
+		v.addf(path, "%v", err)
+	case info.IsDir():
+		v.addf(path, "%s is a directory", name)
+	}
+}
+
+func (v *validator) err() error {
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: v.errs}
+}
+
+// Validate checks the current config, all problems found are returned in a *ValidationError
+func (cfg *Config) Validate() error {
+	return cfg.snapshot().validate()
+}
+
 func (cfg *config) validate() error {
-	var err error
+	v := &validator{}
 
 	// Service section
-	switch {
-	case strings.TrimSpace(cfg.ServiceName) == "":
-		return errors.New("service name is required")
-	case cfg.HTTPort == 0:
-		return errors.New("service port is required")
+	v.required("serviceName", cfg.ServiceName)
+
+	singlePort := !cfg.Security.Insecure || cfg.HttpOtions.H2CEnabled
+
+	if cfg.HTTPort == 0 {
+		v.addf("httpPort", "value is required")
+	}
+	v.port("httpPort", cfg.HTTPort)
+	v.port("grpcPort", cfg.GRPCPort)
+	v.port("adminPort", cfg.AdminPort)
+
+	if cfg.ShutdownDrainSeconds < 0 {
+		v.addf("shutdownDrainSeconds", "value must not be negative")
+	}
+
+	// grpcPort keeps its default when omitted, only an explicit clash with httpPort is rejected
+	if !singlePort && cfg.GRPCPort != 0 && cfg.GRPCPort == cfg.HTTPort {
+		v.addf("grpcPort", "must be different from httpPort when tls and h2c are disabled")
+	}
+
+	if cfg.AdminPort != 0 && (cfg.AdminPort == cfg.HTTPort || cfg.AdminPort == cfg.GRPCPort) {
+		v.addf("adminPort", "must be different from the service ports")
 	}
 
 	// TLS settings
 	if !cfg.Security.Insecure {
-		switch {
-		case strings.TrimSpace(cfg.Security.TLSCertFile) == "":
-			return errors.New("tls cert file is required")
-		case strings.TrimSpace(cfg.Security.TLSKeyFile) == "":
-			return errors.New("tls key file is required")
-		case strings.TrimSpace(cfg.Security.ServerName) == "":
-			return errors.New("server name is required")
-		}
+		cfg.Security.validate(v, "security")
 	}
 
 	// Databases validation
-	for _, db := range cfg.Databases {
-		err = validateDBOptions(db)
-		if err != nil {
-			return err
+	dbNames := make(map[string]int, len(cfg.Databases))
+	for i, db := range cfg.Databases {
+		path := fmt.Sprintf("databases[%d]", i)
+
+		if db == nil {
+			v.addf(path, "value is required")
+			continue
+		}
+
+		db.validate(v, path)
+
+		if db.Metadata == nil || db.Metadata.Name == "" {
+			continue
 		}
+		if j, ok := dbNames[db.Metadata.Name]; ok {
+			v.addf(path+".metadata.name", "duplicate database name %q, also used by databases[%d]", db.Metadata.Name, j)
+			continue
+		}
+		dbNames[db.Metadata.Name] = i
 	}
 
 	// External services validation
-	for _, srv := range cfg.ExternalServices {
-		err = validateService(srv)
-		if err != nil {
-			return nil
+	srvNames := make(map[string]int, len(cfg.ExternalServices))
+	for i, srv := range cfg.ExternalServices {
+		path := fmt.Sprintf("externalServices[%d]", i)
+
+		if srv == nil {
+			v.addf(path, "value is required")
+			continue
+		}
+
+		srv.validate(v, path)
+
+		// Connections are looked up by the lower cased name
+		name := strings.ToLower(srv.Name)
+		if name == "" {
+			continue
+		}
+		if j, ok := srvNames[name]; ok {
+			v.addf(path+".name", "duplicate service name %q, also used by externalServices[%d]", srv.Name, j)
+			continue
 		}
+		srvNames[name] = i
+	}
+
+	// Auth section
+	if cfg.Auth != nil {
+		cfg.Auth.validate(v, "auth")
+	}
+
+	return v.err()
+}
+
+func (auth *authOptions) validate(v *validator, path string) {
+	v.required(path+".signingKey", auth.SigningKey)
+
+	if auth.SigningMethod != "" && !contains(signingMethods, auth.SigningMethod) {
+		v.addf(path+".signingMethod", "signing method %s not supported. Supported methods are %v", auth.SigningMethod, signingMethods)
+	}
+}
+
+func (sec *securityOptions) validate(v *validator, path string) {
+	if v.required(path+".tlsCert", sec.TLSCertFile) {
+		v.fileExists(path+".tlsCert", sec.TLSCertFile)
+	}
+	if v.required(path+".tlsKey", sec.TLSKeyFile) {
+		v.fileExists(path+".tlsKey", sec.TLSKeyFile)
 	}
+	v.required(path+".serverName", sec.ServerName)
 
-	return nil
+	if _, err := tlsutil.ParseVersion(sec.MinVersion); err != nil {
+		v.addf(path+".minVersion", "%v", err)
+	}
+	if _, err := tlsutil.ParseCipherSuites(sec.CipherSuites); err != nil {
+		v.addf(path+".cipherSuites", "%v", err)
+	}
+
+	clientAuth, err := tlsutil.ParseClientAuth(sec.ClientAuth)
+	if err != nil {
+		v.addf(path+".clientAuth", "%v", err)
+	}
+
+	switch {
+	case strings.TrimSpace(sec.ClientCAFile) != "":
+		v.fileExists(path+".clientCA", sec.ClientCAFile)
+	case err == nil && clientAuth != tls.NoClientCert && strings.TrimSpace(sec.ClientAuth) != "":
+		// The default request mode verifies client certificates against the service certificate
+		v.addf(path+".clientCA", "value is required when client auth is enabled")
+	}
 }
 
-func validateDBOptions(db *databaseOptions) error {
+func (db *databaseOptions) validate(v *validator, path string) {
 	switch db.Type {
 	case SQLDBType:
 	case RedisDBType:
 	case "":
-		return fmt.Errorf("database type is required. Supported types are %v", dbTypes)
+		v.addf(path+".type", "value is required. Supported types are %v", dbTypes)
+		return
 	default:
-		return fmt.Errorf("database type %s not known. Supported types are %v", db.Type, dbTypes)
+		v.addf(path+".type", "database type %s not known. Supported types are %v", db.Type, dbTypes)
+		return
+	}
+
+	if db.Type == SQLDBType && db.Metadata != nil && db.Metadata.Dialect != "" && !contains(sqlDialects, db.Metadata.Dialect) {
+		v.addf(path+".metadata.dialect", "dialect %s not known. Supported dialects are %v", db.Metadata.Dialect, sqlDialects)
 	}
 
-	if db.Required {
+	if db.Metadata != nil && db.Metadata.SSLMode != "" {
 		switch {
-		case strings.TrimSpace(db.Metadata.Name) == "":
-			return errors.New("database name is required")
-		case strings.TrimSpace(db.Address) == "":
-			return errors.New("database address is required")
-		case strings.TrimSpace(db.User) == "" && db.Address == SQLDBType:
-			return errors.New("database user is required")
-		case strings.TrimSpace(db.Password) == "" && db.Address == SQLDBType:
-			return errors.New("database password is required")
-		case strings.TrimSpace(db.Schema) == "" && db.Address == SQLDBType:
-			return errors.New("database schema is required")
+		case db.Type != SQLDBType || db.Metadata.Dialect != "postgres":
+			v.addf(path+".metadata.sslMode", "only supported by postgres databases")
+		case !contains(sslModes, db.Metadata.SSLMode):
+			v.addf(path+".metadata.sslMode", "ssl mode %s not known. Supported modes are %v", db.Metadata.SSLMode, sslModes)
 		}
 	}
 
-	return nil
+	if !db.Required {
+		return
+	}
+
+	if db.Metadata == nil {
+		v.addf(path+".metadata.name", "value is required")
+	} else {
+		v.required(path+".metadata.name", db.Metadata.Name)
+	}
+
+	v.required(path+".address", db.Address)
+
+	if db.Type == SQLDBType {
+		v.required(path+".user", db.User)
+		v.required(path+".password", db.Password)
+		v.required(path+".schema", db.Schema)
+	}
 }
 
-func validateService(srv *externalServiceOptions) error {
+func (srv *externalServiceOptions) validate(v *validator, path string) {
+	v.required(path+".name", srv.Name)
+
 	if !srv.Required {
-		return nil
+		return
 	}
 
-	switch {
-	case strings.TrimSpace(srv.Name) == "":
-		return errors.New("service name is required")
-	case strings.TrimSpace(srv.ServerName) == "" && !srv.Insecure:
-		return fmt.Errorf("service %s tls server name is required", strings.ToLower(srv.Name))
-	case strings.TrimSpace(srv.TLSCertFile) == "" && !srv.Insecure:
-		return fmt.Errorf("service %s tls cert is required", strings.ToLower(srv.Name))
-	case strings.TrimSpace(srv.Address) == "":
-		return fmt.Errorf("service %s address is required", strings.ToLower(srv.Name))
+	v.required(path+".address", srv.Address)
+
+	if !srv.Insecure {
+		v.required(path+".serverName", srv.ServerName)
+		if v.required(path+".tlsCert", srv.TLSCertFile) {
+			v.fileExists(path+".tlsCert", srv.TLSCertFile)
+		}
 	}
+}
 
-	return nil
+func contains(vals []string, val string) bool {
+	for _, v := range vals {
+		if v == val {
+			return true
+		}
+	}
+	return false
 }
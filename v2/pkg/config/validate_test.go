@@ -0,0 +1,163 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name: "valid",
+			content: `
+serviceName: orders
+httpPort: 8080
+grpcPort: 8081
+security:
+  insecure: true
+databases:
+  - required: true
+    type: sqlDatabase
+    address: localhost:3306
+    user: root
+    password: secret
+    schema: orders
+    metadata:
+      name: orders
+      dialect: postgres
+externalServices:
+  - name: payments
+    required: true
+    address: localhost:9000
+    insecure: true
+`,
+		},
+		{
+			name: "all errors reported",
+			content: `
+httpPort: 70000
+grpcPort: 70000
+adminPort: 8080
+security:
+  tlsCert: /does/not/exist.pem
+  minVersion: "1.4"
+databases:
+  - required: true
+    type: sqlDatabase
+    metadata:
+      name: orders
+      dialect: oracle
+  - type: redisDatabase
+    metadata:
+      name: orders
+  - type: mongo
+externalServices:
+  - name: payments
+    required: true
+  - name: Payments
+    insecure: true
+`,
+			want: []string{
+				"serviceName",
+				"httpPort",
+				"grpcPort",
+				"security.tlsCert",
+				"security.tlsKey",
+				"security.serverName",
+				"security.minVersion",
+				"databases[0].metadata.dialect",
+				"databases[0].address",
+				"databases[0].user",
+				"databases[0].password",
+				"databases[0].schema",
+				"databases[1].metadata.name",
+				"databases[2].type",
+				"externalServices[0].address",
+				"externalServices[0].serverName",
+				"externalServices[0].tlsCert",
+				"externalServices[1].name",
+			},
+		},
+		{
+			name:    "separate grpc port",
+			content: "serviceName: orders\nhttpPort: 8080\ngrpcPort: 8080\nsecurity:\n  insecure: true\n",
+			want:    []string{"grpcPort"},
+		},
+		{
+			name:    "default grpc port",
+			content: "serviceName: orders\nhttpPort: 5600\nsecurity:\n  insecure: true\n",
+		},
+		{
+			name:    "auth",
+			content: "serviceName: orders\nhttpPort: 8080\ngrpcPort: 8081\nsecurity:\n  insecure: true\nauth:\n  signingMethod: HS999\n",
+			want:    []string{"auth.signingKey", "auth.signingMethod"},
+		},
+		{
+			name: "postgres ssl mode",
+			content: `
+serviceName: orders
+httpPort: 8080
+grpcPort: 8081
+security:
+  insecure: true
+databases:
+  - type: sqlDatabase
+    metadata:
+      dialect: postgres
+      sslMode: required
+  - type: sqlDatabase
+    metadata:
+      dialect: mysql
+      sslMode: require
+`,
+			want: []string{"databases[0].metadata.sslMode", "databases[1].metadata.sslMode"},
+		},
+		{
+			name:    "h2c serves grpc on the service port",
+			content: "serviceName: orders\nhttpPort: 8080\nhttpOptions:\n  h2cEnabled: true\nsecurity:\n  insecure: true\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newConfig()
+			err := yaml.UnmarshalStrict([]byte(tt.content), cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = cfg.validate()
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("validate() error = %v", err)
+				}
+				return
+			}
+
+			validationErr := &ValidationError{}
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("validate() error = %v, want *ValidationError", err)
+			}
+
+			paths := make([]string, 0, len(validationErr.Errors))
+			for _, fieldErr := range validationErr.Errors {
+				paths = append(paths, fieldErr.Path)
+			}
+
+			sort.Strings(paths)
+			sort.Strings(tt.want)
+
+			if !reflect.DeepEqual(paths, tt.want) {
+				t.Errorf("validate() paths = %v, want %v\nerror: %v", paths, tt.want, err)
+			}
+		})
+	}
+}
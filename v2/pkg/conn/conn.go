@@ -4,9 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"net/url"
 	"time"
 
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm/logger"
 
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
@@ -16,10 +18,13 @@ import (
 	redis "github.com/go-redis/redis/v8"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
 	"gorm.io/gorm"
 
 	// Imports mysql driver
 	_ "github.com/go-sql-driver/mysql"
+	// Imports postgres driver registered as pgx
+	_ "github.com/jackc/pgx/v4/stdlib"
 )
 
 // DBConnPoolOptions contains options for customizing the connection pool
@@ -37,9 +42,19 @@ type DBOptions struct {
 	User     string
 	Password string
 	Schema   string
-	ConnPool *DBConnPoolOptions
+	// Postgres connection options
+	SSLMode    string
+	SearchPath string
+	TimeZone   string
+	ConnPool   *DBConnPoolOptions
 }
 
+// SQL dialects
+const (
+	DialectMySQL    = "mysql"
+	DialectPostgres = "postgres"
+)
+
 // OpenGormConn open a connection to sql database using gorm orm
 func OpenGormConn(opt *DBOptions) (*gorm.DB, error) {
 	return toSQLDBUsingORM(opt)
@@ -50,6 +65,70 @@ func OpenSQLDBConn(opt *DBOptions) (*sql.DB, error) {
 	return toSQLDB(opt)
 }
 
+// dialect returns the sql dialect of the database, defaults to mysql
+func (opt *DBOptions) dialect() string {
+	if opt.Dialect == "" {
+		return DialectMySQL
+	}
+	return strings.ToLower(opt.Dialect)
+}
+
+// DSN returns the data source name for connecting to the database using its dialect
+func (opt *DBOptions) DSN() (string, error) {
+	switch opt.dialect() {
+	case DialectMySQL:
+		// add MySQL driver specific parameter to parse date/time
+		param := "charset=utf8&parseTime=true"
+
+		return fmt.Sprintf("%s:%s@tcp(%s)/%s?%s",
+			opt.User,
+			opt.Password,
+			opt.Address,
+			opt.Schema,
+			param,
+		), nil
+
+	case DialectPostgres:
+		params := url.Values{}
+		if opt.SSLMode != "" {
+			params.Set("sslmode", opt.SSLMode)
+		}
+		if opt.SearchPath != "" {
+			params.Set("search_path", opt.SearchPath)
+		}
+		if opt.TimeZone != "" {
+			params.Set("timezone", opt.TimeZone)
+		}
+
+		dsn := &url.URL{
+			Scheme:   "postgres",
+			Host:     opt.Address,
+			Path:     "/" + opt.Schema,
+			RawQuery: params.Encode(),
+		}
+
+		switch {
+		case opt.Password != "":
+			dsn.User = url.UserPassword(opt.User, opt.Password)
+		case opt.User != "":
+			dsn.User = url.User(opt.User)
+		}
+
+		return dsn.String(), nil
+
+	default:
+		return "", errors.Errorf("unsupported sql dialect %q", opt.Dialect)
+	}
+}
+
+// driverName returns the database/sql driver registered for the dialect
+func (opt *DBOptions) driverName() string {
+	if opt.dialect() == DialectPostgres {
+		return "pgx"
+	}
+	return opt.dialect()
+}
+
 // opens a connection to SQL database returning the gorm database client
 func toSQLDBUsingORM(opt *DBOptions) (*gorm.DB, error) {
 	// Options should not be nil
@@ -57,24 +136,25 @@ func toSQLDBUsingORM(opt *DBOptions) (*gorm.DB, error) {
 		return nil, errors.New("nil db options not allowed")
 	}
 
-	// add MySQL driver specific parameter to parse date/time
-	param := "charset=utf8&parseTime=true"
+	dsn, err := opt.DSN()
+	if err != nil {
+		return nil, err
+	}
 
-	dsn := fmt.Sprintf("%s:%s@tcp(%s)/%s?%s",
-		opt.User,
-		opt.Password,
-		opt.Address,
-		opt.Schema,
-		param,
-	)
+	var dialector gorm.Dialector
+	if opt.dialect() == DialectPostgres {
+		dialector = postgres.Open(dsn)
+	} else {
+		dialector = mysql.Open(dsn)
+	}
 
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+	db, err := gorm.Open(dialector, &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Silent),
 	})
 	if err != nil {
 		return nil, errors.Wrap(err,
 			fmt.Sprintf(
-				"(GORM) failed to open connection to mysql database [name: %s] [address: %s]", opt.Name, opt.Address,
+				"(GORM) failed to open connection to %s database [name: %s] [address: %s]", opt.dialect(), opt.Name, opt.Address,
 			),
 		)
 	}
@@ -84,17 +164,7 @@ func toSQLDBUsingORM(opt *DBOptions) (*gorm.DB, error) {
 		return nil, err
 	}
 
-	if opt.ConnPool != nil {
-		if opt.ConnPool.MaxIdleConns != 0 {
-			sqlDB.SetMaxIdleConns(int(opt.ConnPool.MaxIdleConns))
-		}
-		if opt.ConnPool.MaxOpenConns != 0 {
-			sqlDB.SetMaxOpenConns(int(opt.ConnPool.MaxOpenConns))
-		}
-		if opt.ConnPool.MaxLifetime != 0 {
-			sqlDB.SetConnMaxLifetime(opt.ConnPool.MaxLifetime)
-		}
-	}
+	setConnPool(sqlDB, opt.ConnPool)
 
 	return db, nil
 }
@@ -106,48 +176,40 @@ func toSQLDB(opt *DBOptions) (*sql.DB, error) {
 		return nil, errors.New("nil db options not allowed")
 	}
 
-	// add MySQL driver specific parameter to parse date/time
-	param := "charset=utf8&parseTime=true"
-
-	dsn := fmt.Sprintf("%s:%s@tcp(%s)/%s?%s",
-		opt.User,
-		opt.Password,
-		opt.Address,
-		opt.Schema,
-		param,
-	)
-
-	dialect := func() string {
-		if opt.Dialect == "" {
-			return "mysql"
-		}
-		return opt.Dialect
-	}()
+	dsn, err := opt.DSN()
+	if err != nil {
+		return nil, err
+	}
 
-	sqlDB, err := sql.Open(dialect, dsn)
+	sqlDB, err := sql.Open(opt.driverName(), dsn)
 	if err != nil {
 		return nil, errors.Wrap(err,
 			fmt.Sprintf(
-				"(SQL) failed to open connection to mysql database [name: %s] [address: %s]", opt.Name, opt.Address,
+				"(SQL) failed to open connection to %s database [name: %s] [address: %s]", opt.dialect(), opt.Name, opt.Address,
 			),
 		)
 	}
 
-	if opt.ConnPool != nil {
-		if opt.ConnPool.MaxIdleConns != 0 {
-			sqlDB.SetMaxIdleConns(int(opt.ConnPool.MaxIdleConns))
-		}
-		if opt.ConnPool.MaxOpenConns != 0 {
-			sqlDB.SetMaxOpenConns(int(opt.ConnPool.MaxOpenConns))
-		}
-		if opt.ConnPool.MaxLifetime != 0 {
-			sqlDB.SetConnMaxLifetime(opt.ConnPool.MaxLifetime)
-		}
-	}
+	setConnPool(sqlDB, opt.ConnPool)
 
 	return sqlDB, nil
 }
 
+func setConnPool(sqlDB *sql.DB, pool *DBConnPoolOptions) {
+	if pool == nil {
+		return
+	}
+	if pool.MaxIdleConns != 0 {
+		sqlDB.SetMaxIdleConns(int(pool.MaxIdleConns))
+	}
+	if pool.MaxOpenConns != 0 {
+		sqlDB.SetMaxOpenConns(int(pool.MaxOpenConns))
+	}
+	if pool.MaxLifetime != 0 {
+		sqlDB.SetConnMaxLifetime(pool.MaxLifetime)
+	}
+}
+
 // OpenRedisConn opens a tcp connection to the redis database returning the client.
 func OpenRedisConn(opt *redis.Options) *redis.Client {
 	return redis.NewClient(opt)
@@ -191,6 +253,25 @@ func DialService(ctx context.Context, opt *GRPCDialOptions) (*grpc.ClientConn, e
 	return grpc.DialContext(ctx, opt.Address, dopts...)
 }
 
+// WaitForConnReady starts connecting cc if idle and blocks until it is ready, fails to connect or ctx is done
+func WaitForConnReady(ctx context.Context, cc *grpc.ClientConn) error {
+	cc.Connect()
+
+	for {
+		state := cc.GetState()
+		switch state {
+		case connectivity.Ready:
+			return nil
+		case connectivity.TransientFailure, connectivity.Shutdown:
+			return errors.Errorf("connection is in %s state", state)
+		}
+
+		if !cc.WaitForStateChange(ctx, state) {
+			return ctx.Err()
+		}
+	}
+}
+
 func waitForReadyInterceptor(
 	ctx context.Context,
 	method string,
@@ -0,0 +1,262 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// SeverityCritical indicates a failing check fails the probe
+	SeverityCritical = "critical"
+	// SeverityDegraded indicates a failing check is reported but does not fail the probe
+	SeverityDegraded = "degraded"
+)
+
+// StatusDegraded indicates the service is serving but a non critical check is failing
+const StatusDegraded = "degraded"
+
+// Checker checks the health of a component such as a queue, disk, cache or downstream API
+type Checker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+type checkerFunc struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+func (c *checkerFunc) Name() string {
+	return c.name
+}
+
+func (c *checkerFunc) Check(ctx context.Context) error {
+	return c.fn(ctx)
+}
+
+// NewChecker creates a checker from a function
+func NewChecker(name string, fn func(ctx context.Context) error) Checker {
+	return &checkerFunc{name: name, fn: fn}
+}
+
+// CheckerOptions contains options for registering a custom checker
+type CheckerOptions struct {
+	Checker Checker
+	// Kind groups the check in reports e.g queue, disk. Defaults to custom
+	Kind string
+	// Severity is either SeverityCritical or SeverityDegraded, defaults to critical
+	Severity string
+	// Probes are the probe types that run the check, defaults to readiness
+	Probes []string
+	// CacheTTL is how long a result is reused before the check is run again. Zero disables caching
+	CacheTTL time.Duration
+}
+
+// Registry holds custom checkers that are run by probes
+type Registry struct {
+	mu     sync.RWMutex
+	checks []*check
+}
+
+// NewRegistry creates an empty checker registry
+func NewRegistry() *Registry {
+	return &Registry{checks: make([]*check, 0)}
+}
+
+// DefaultRegistry is the registry used by probes that do not specify one
+var DefaultRegistry = NewRegistry()
+
+// Register adds a checker to the default registry
+func Register(opt *CheckerOptions) error {
+	return DefaultRegistry.Register(opt)
+}
+
+// Register adds a checker to the registry
+func (r *Registry) Register(opt *CheckerOptions) error {
+	if opt == nil || opt.Checker == nil {
+		return errors.New("nil checker not allowed")
+	}
+
+	severity := opt.Severity
+	switch severity {
+	case "":
+		severity = SeverityCritical
+	case SeverityCritical, SeverityDegraded:
+	default:
+		return fmt.Errorf("unknown severity %q for checker %s", severity, opt.Checker.Name())
+	}
+
+	probes := opt.Probes
+	if len(probes) == 0 {
+		probes = []string{ProbeReadiness}
+	}
+	for _, probe := range probes {
+		switch probe {
+		case ProbeLiveNess, ProbeReadiness, ProbeStartup:
+		default:
+			return fmt.Errorf("unknown probe %q for checker %s", probe, opt.Checker.Name())
+		}
+	}
+
+	kind := opt.Kind
+	if kind == "" {
+		kind = "custom"
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, c := range r.checks {
+		if c.name == opt.Checker.Name() && c.kind == kind {
+			return fmt.Errorf("checker %s already registered", opt.Checker.Name())
+		}
+	}
+
+	r.checks = append(r.checks, &check{
+		name:     opt.Checker.Name(),
+		kind:     kind,
+		severity: severity,
+		probes:   probes,
+		ttl:      opt.CacheTTL,
+		fn:       opt.Checker.Check,
+	})
+
+	return nil
+}
+
+// checksFor returns the checks assigned to the probe type
+func (r *Registry) checksFor(probe string) []*check {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	checks := make([]*check, 0, len(r.checks))
+	for _, c := range r.checks {
+		for _, p := range c.probes {
+			if p == probe || probe == "" {
+				checks = append(checks, c)
+				break
+			}
+		}
+	}
+	return checks
+}
+
+// check is a single health check whose result may be cached
+type check struct {
+	name     string
+	kind     string
+	severity string
+	probes   []string
+	ttl      time.Duration
+	fn       func(ctx context.Context) error
+
+	mu       sync.Mutex
+	last     *CheckResult
+	lastTime time.Time
+}
+
+func (c *check) run(ctx context.Context, timeout time.Duration) *CheckResult {
+	if c.ttl > 0 {
+		c.mu.Lock()
+		last, lastTime := c.last, c.lastTime
+		c.mu.Unlock()
+
+		if last != nil && time.Since(lastTime) < c.ttl {
+			cached := *last
+			cached.Cached = true
+			return &cached
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.fn(ctx)
+
+	res := &CheckResult{
+		Name:     c.name,
+		Kind:     c.kind,
+		Severity: c.severity,
+		Status:   StatusUp,
+		Latency:  time.Since(start),
+	}
+	if err != nil {
+		res.Status = StatusDown
+		res.Error = err.Error()
+	}
+
+	if c.ttl > 0 {
+		c.mu.Lock()
+		c.last, c.lastTime = res, start
+		c.mu.Unlock()
+	}
+
+	return res
+}
+
+// runChecks runs checks concurrently, each bounded by timeout or the deadline of ctx whichever is sooner
+func runChecks(ctx context.Context, checks []*check, timeout time.Duration) []*CheckResult {
+	var (
+		mu      = &sync.Mutex{}
+		results = make([]*CheckResult, 0, len(checks))
+		wg      = &sync.WaitGroup{}
+	)
+
+	for _, c := range checks {
+		wg.Add(1)
+
+		go func(c *check) {
+			defer wg.Done()
+
+			res := c.run(ctx, timeout)
+
+			mu.Lock()
+			results = append(results, res)
+			mu.Unlock()
+		}(c)
+	}
+
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Kind != results[j].Kind {
+			return results[i].Kind < results[j].Kind
+		}
+		return results[i].Name < results[j].Name
+	})
+
+	return results
+}
+
+// overallStatus is down if a critical check fails and degraded if only non critical checks fail
+func overallStatus(results []*CheckResult) string {
+	status := StatusUp
+	for _, res := range results {
+		if res.Status == StatusUp {
+			continue
+		}
+		if res.Severity == SeverityDegraded {
+			status = StatusDegraded
+			continue
+		}
+		return StatusDown
+	}
+	return status
+}
+
+// failureMessage formats failed results
+func failureMessage(results []*CheckResult) string {
+	msgs := make([]string, 0)
+	for _, res := range results {
+		if res.Status != StatusUp {
+			msgs = append(msgs, fmt.Sprintf("[%s] %s check failed (%s): %s", res.Name, res.Kind, res.Severity, res.Error))
+		}
+	}
+	return strings.Join(msgs, "; ")
+}
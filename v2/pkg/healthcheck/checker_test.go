@@ -0,0 +1,128 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestOverallStatus(t *testing.T) {
+	var (
+		up       = &CheckResult{Status: StatusUp, Severity: SeverityCritical}
+		degraded = &CheckResult{Status: StatusDown, Severity: SeverityDegraded}
+		down     = &CheckResult{Status: StatusDown, Severity: SeverityCritical}
+	)
+
+	tests := []struct {
+		name    string
+		results []*CheckResult
+		want    string
+	}{
+		{name: "no checks", want: StatusUp},
+		{name: "all up", results: []*CheckResult{up, up}, want: StatusUp},
+		{name: "non critical failing", results: []*CheckResult{up, degraded}, want: StatusDegraded},
+		{name: "critical failing", results: []*CheckResult{up, down}, want: StatusDown},
+		{name: "critical failing after non critical", results: []*CheckResult{degraded, down}, want: StatusDown},
+		{name: "non critical failing after critical", results: []*CheckResult{down, degraded}, want: StatusDown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := overallStatus(tt.results); got != tt.want {
+				t.Errorf("overallStatus() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckCache(t *testing.T) {
+	tests := []struct {
+		name       string
+		ttl        time.Duration
+		wait       time.Duration
+		wantCalls  int
+		wantCached bool
+	}{
+		{name: "caching disabled", wantCalls: 2},
+		{name: "cached", ttl: time.Hour, wantCalls: 1, wantCached: true},
+		{name: "expired", ttl: 10 * time.Millisecond, wait: 20 * time.Millisecond, wantCalls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			c := &check{name: "queue", ttl: tt.ttl, fn: func(context.Context) error {
+				calls++
+				return errors.New("unavailable")
+			}}
+
+			c.run(context.Background(), time.Second)
+			time.Sleep(tt.wait)
+			res := c.run(context.Background(), time.Second)
+
+			if calls != tt.wantCalls {
+				t.Errorf("check ran %d times, want %d", calls, tt.wantCalls)
+			}
+			if res.Cached != tt.wantCached {
+				t.Errorf("result cached = %v, want %v", res.Cached, tt.wantCached)
+			}
+			if res.Status != StatusDown || res.Error != "unavailable" {
+				t.Errorf("result = %+v, want failing check", res)
+			}
+		})
+	}
+}
+
+func TestRegistryChecksFor(t *testing.T) {
+	registry := NewRegistry()
+
+	noop := func(context.Context) error { return nil }
+	for _, opt := range []*CheckerOptions{
+		{Checker: NewChecker("queue", noop)},
+		{Checker: NewChecker("disk", noop), Probes: []string{ProbeLiveNess, ProbeReadiness}},
+		{Checker: NewChecker("cache", noop), Probes: []string{ProbeStartup}, Severity: SeverityDegraded},
+	} {
+		err := registry.Register(opt)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		probe string
+		want  []string
+	}{
+		{probe: ProbeLiveNess, want: []string{"disk"}},
+		{probe: ProbeReadiness, want: []string{"disk", "queue"}},
+		{probe: ProbeStartup, want: []string{"cache"}},
+		{probe: "", want: []string{"cache", "disk", "queue"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.probe, func(t *testing.T) {
+			names := make([]string, 0)
+			for _, c := range registry.checksFor(tt.probe) {
+				names = append(names, c.name)
+			}
+			sort.Strings(names)
+
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("checksFor(%q) = %v, want %v", tt.probe, names, tt.want)
+			}
+		})
+	}
+
+	for _, opt := range []*CheckerOptions{
+		nil,
+		{Checker: NewChecker("queue", noop)},
+		{Checker: NewChecker("other", noop), Severity: "warning"},
+		{Checker: NewChecker("other", noop), Probes: []string{"ready"}},
+	} {
+		if err := registry.Register(opt); err == nil {
+			t.Errorf("Register(%+v) expected error", opt)
+		}
+	}
+}
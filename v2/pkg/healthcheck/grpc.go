@@ -0,0 +1,117 @@
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/gidyon/micro/v2"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const defaultGRPCHealthInterval = 10 * time.Second
+
+// GRPCHealthOptions contains options for driving the gRPC health service from dependency checks
+type GRPCHealthOptions struct {
+	Service *micro.Service
+	// Interval is the period between dependency checks, defaults to 10 seconds
+	Interval time.Duration
+	// Services are the gRPC service names whose serving status follows the dependency checks.
+	// Defaults to all services registered on the gRPC server.
+	Services []string
+	// Registry contains custom checkers run in addition to the dependency checks, defaults to DefaultRegistry.
+	// All registered checkers are run regardless of their probes.
+	Registry *Registry
+}
+
+// RegisterGRPCHealth runs the dependency checks used by RegisterProbe periodically once the service is ready,
+// updating the serving status reported by the standard grpc.health.v1.Health service.
+// Only critical check failures make the status NOT_SERVING.
+// Watch callers are notified on every status change. The status becomes NOT_SERVING when the service shuts down.
+// It must be called before the service is ready.
+func RegisterGRPCHealth(opt *GRPCHealthOptions) error {
+	if opt.Service.HookPhaseRan(micro.PhaseReady) {
+		return errors.New("grpc health checks must be registered before the service is ready")
+	}
+	if opt.Interval <= 0 {
+		opt.Interval = defaultGRPCHealthInterval
+	}
+	if opt.Registry == nil {
+		opt.Registry = DefaultRegistry
+	}
+
+	var (
+		service = opt.Service
+		stop    = make(chan struct{})
+	)
+
+	service.OnReady(&micro.Hook{
+		Name: "grpc health checks",
+		Fn: func(ctx context.Context) error {
+			services := opt.Services
+			if len(services) == 0 {
+				for name := range service.GRPCServer().GetServiceInfo() {
+					services = append(services, name)
+				}
+			}
+
+			checks := append(dependencyChecks(service, 0), opt.Registry.checksFor("")...)
+
+			go watchGRPCHealth(service, services, checks, opt.Interval, stop)
+
+			return nil
+		},
+	})
+
+	service.OnStop(&micro.Hook{
+		Name: "grpc health checks",
+		Fn: func(ctx context.Context) error {
+			close(stop)
+			return nil
+		},
+	})
+
+	return nil
+}
+
+func watchGRPCHealth(service *micro.Service, services []string, checks []*check, interval time.Duration, stop <-chan struct{}) {
+	// Ready hooks such as migrations run before the service is reported as serving
+	for service.State() < micro.StateServing {
+		select {
+		case <-stop:
+			return
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	lastStatus := healthpb.HealthCheckResponse_SERVING
+
+	for {
+		results := runChecks(context.Background(), checks, interval)
+
+		status := healthpb.HealthCheckResponse_SERVING
+		if overallStatus(results) == StatusDown {
+			status = healthpb.HealthCheckResponse_NOT_SERVING
+		}
+
+		if status != lastStatus {
+			service.Logger().Warningf("grpc health status changed to %s: %s", status, failureMessage(results))
+			lastStatus = status
+		}
+
+		// Updates are ignored by the health server once the service starts shutting down
+		service.HealthServer().SetServingStatus("", status)
+		for _, name := range services {
+			service.HealthServer().SetServingStatus(name, status)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package healthcheck
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestRegisterGRPCHealth(t *testing.T) {
+	service := newTestService(t)
+
+	err := RegisterGRPCHealth(&GRPCHealthOptions{Service: service, Interval: 50 * time.Millisecond, Registry: NewRegistry()})
+	if err != nil {
+		t.Fatalf("RegisterGRPCHealth() before the service is ready error = %v", err)
+	}
+
+	runTestService(t, service)
+
+	res, err := healthpb.NewHealthClient(service.ClientConn()).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("health status = %s, want %s", res.Status, healthpb.HealthCheckResponse_SERVING)
+	}
+
+	// The ready hook that starts the checks would never run
+	err = RegisterGRPCHealth(&GRPCHealthOptions{Service: service, Registry: NewRegistry()})
+	if err == nil {
+		t.Error("RegisterGRPCHealth() after the service is ready returned no error")
+	}
+}
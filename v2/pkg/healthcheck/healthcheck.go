@@ -1,15 +1,16 @@
 package healthcheck
 
 import (
-	"database/sql"
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/gidyon/micro/v2"
-	"github.com/gidyon/micro/v2/pkg/config"
-	"github.com/go-redis/redis/v8"
-	"gorm.io/gorm"
+	"github.com/gidyon/micro/v2/pkg/conn"
 )
 
 const (
@@ -21,30 +22,103 @@ const (
 	ProbeStartup = "startup"
 )
 
+const (
+	// StatusUp indicates a dependency or service is healthy
+	StatusUp = "up"
+	// StatusDown indicates a dependency or service is unhealthy
+	StatusDown = "down"
+)
+
+const defaultCheckTimeout = 5 * time.Second
+
 // ProbeOptions contains data and options required for doing healthcheck
 type ProbeOptions struct {
-	successMsg   string
-	Service      *micro.Service
+	successMsg string
+	Service    *micro.Service
+	// AutoMigrator runs once when the service is about to be reported as ready, or immediately if
+	// the probe is registered later. The startup and readiness probes fail while it is running.
+	// It is set with Service.SetAutoMigrator, so only the first probe registered with an AutoMigrator
+	// for a service runs it and others are logged.
 	AutoMigrator func() error
 	Type         string
+	// Timeout is the maximum duration of each dependency check, defaults to 5 seconds.
+	// A shorter deadline on the request context takes precedence.
+	Timeout time.Duration
+	// JSON always writes the report as JSON. Otherwise JSON is written when requested
+	// through the Accept header or the format=json query parameter.
+	JSON bool
+	// Registry contains custom checkers run in addition to the dependency checks, defaults to DefaultRegistry
+	Registry *Registry
+	// CacheTTL is how long dependency check results are reused. Zero disables caching
+	CacheTTL time.Duration
+}
+
+// CheckResult is the outcome of checking a single dependency
+type CheckResult struct {
+	Name     string        `json:"name"`
+	Kind     string        `json:"kind"`
+	Severity string        `json:"severity"`
+	Status   string        `json:"status"`
+	Latency  time.Duration `json:"-"`
+	Error    string        `json:"error,omitempty"`
+	Cached   bool          `json:"cached,omitempty"`
+}
+
+// MarshalJSON formats latency in milliseconds
+func (res *CheckResult) MarshalJSON() ([]byte, error) {
+	type alias CheckResult
+	return json.Marshal(&struct {
+		*alias
+		LatencyMillis float64 `json:"latencyMs"`
+	}{
+		alias:         (*alias)(res),
+		LatencyMillis: float64(res.Latency.Microseconds()) / 1000,
+	})
 }
 
-// RegisterProbe ...
+// Report is the health report written by probes
+type Report struct {
+	Service string         `json:"service"`
+	Probe   string         `json:"probe"`
+	State   string         `json:"state"`
+	Status  string         `json:"status"`
+	Message string         `json:"message,omitempty"`
+	Checks  []*CheckResult `json:"checks"`
+}
+
+// RegisterProbe returns a handler that checks the service dependencies and the custom checkers assigned to the probe.
+// Liveness probes skip the dependency checks, a failing dependency does not mean the process needs restarting.
+// It responds with 503 if a critical check fails, so that failing pods are taken out of rotation,
+// and with 200 otherwise. The status is degraded when only non critical checks fail.
+// Startup and readiness probes fail until the service is serving, readiness probes also fail once it is draining.
 func RegisterProbe(opt *ProbeOptions) http.HandlerFunc {
+	if opt.AutoMigrator != nil && opt.Service != nil {
+		err := opt.Service.SetAutoMigrator(opt.AutoMigrator)
+		if err != nil {
+			opt.Service.Logger().Warningf("failed to set auto migrator of %s probe: %v", opt.Type, err)
+		}
+	}
 	if opt.AutoMigrator == nil {
 		opt.AutoMigrator = func() error { return nil }
 	}
+	if opt.Timeout <= 0 {
+		opt.Timeout = defaultCheckTimeout
+	}
+	if opt.Registry == nil {
+		opt.Registry = DefaultRegistry
+	}
 
 	var (
 		service = opt.Service
-		cfg     = opt.Service.Config()
+		deps    = &dependencies{}
 	)
 
 	serviceNil := service == nil
-	cfgNil := cfg == nil
+	cfgNil := serviceNil || service.Config() == nil
 
 	// apply defaults
 	if !serviceNil && !cfgNil {
+		cfg := service.Config()
 		switch opt.Type {
 		case ProbeLiveNess:
 			opt.successMsg = fmt.Sprintf("service %q is running correctly :)", cfg.ServiceName())
@@ -62,142 +136,179 @@ func RegisterProbe(opt *ProbeOptions) http.HandlerFunc {
 		// Handle any panic
 		defer func() {
 			if err := recover(); err != nil {
-				errMsg := fmt.Sprintf("unexpected error: %v", err)
-				fmt.Fprintln(w, errMsg)
+				http.Error(w, fmt.Sprintf("unexpected error: %v", err), http.StatusInternalServerError)
 			}
 		}()
 
-		var (
-			mu   = &sync.Mutex{}
-			errs = make([]string, 0)
-			wg   = &sync.WaitGroup{}
-			ctx  = r.Context()
-			err  error
-		)
-
-		appendError := func(errMsg string) {
-			mu.Lock()
-			errs = append(errs, errMsg)
-			mu.Unlock()
-		}
-
 		if serviceNil {
-			w.WriteHeader(http.StatusExpectationFailed)
-			_, err = w.Write([]byte("service is uninitialized"))
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-			}
+			http.Error(w, "service is uninitialized", http.StatusExpectationFailed)
 			return
 		}
 
 		if cfgNil {
-			w.WriteHeader(http.StatusExpectationFailed)
-			_, err = w.Write([]byte("service has no configuration options"))
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-			}
+			http.Error(w, "service has no configuration options", http.StatusExpectationFailed)
 			return
 		}
 
-		// Check sql db connection
-		if len(service.SQLDBs()) > 0 {
-			for name, sqlDB := range service.SQLDBs() {
-				name := name
+		state := service.State()
+
+		report := &Report{
+			Service: service.Config().ServiceName(),
+			Probe:   opt.Type,
+			State:   state.String(),
+			Status:  StatusUp,
+			Checks:  make([]*CheckResult, 0),
+		}
 
-				wg.Add(1)
+		switch {
+		case opt.Type != ProbeLiveNess && state < micro.StateServing:
+			// Service has not finished initializing or running migrations
+			report.Status = StatusDown
+			report.Message = fmt.Sprintf("service %q is %s", report.Service, state)
+			writeReport(w, r, opt, report)
+			return
+		case opt.Type == ProbeReadiness && service.ShuttingDown():
+			// Service stops receiving traffic once it starts shutting down
+			report.Status = StatusDown
+			report.Message = fmt.Sprintf("service %q is shutting down", report.Service)
+			writeReport(w, r, opt, report)
+			return
+		}
 
-				go func(sqlDB *sql.DB) {
-					defer wg.Done()
+		checks := opt.Registry.checksFor(opt.Type)
 
-					err = sqlDB.Ping()
-					if err != nil {
-						appendError(fmt.Sprintf("[%s] failed to ping sql database: %v", name, err))
-						return
-					}
-				}(sqlDB)
-			}
+		// Dependency outages take the service out of rotation, liveness only checks the process itself
+		// so that they do not restart every pod
+		if opt.Type != ProbeLiveNess {
+			checks = append(deps.get(service, opt.CacheTTL), checks...)
 		}
 
-		// Check gorm db connection
-		if len(service.GormDBs()) > 0 {
-			for name, gormDB := range service.GormDBs() {
-				name := name
+		report.Checks = runChecks(r.Context(), checks, opt.Timeout)
+		report.Status = overallStatus(report.Checks)
 
-				wg.Add(1)
+		if report.Status != StatusDown {
+			report.Message = opt.successMsg
+		}
 
-				go func(gormDB *gorm.DB) {
-					defer wg.Done()
+		writeReport(w, r, opt, report)
+	}
+}
 
-					sqlDB, err := gormDB.DB()
-					if err != nil {
-						appendError(fmt.Sprintf("[%s] failed to get sql database from gorm: %v", name, err))
-						return
-					}
+func wantsJSON(r *http.Request, opt *ProbeOptions) bool {
+	return opt.JSON ||
+		r.URL.Query().Get("format") == "json" ||
+		strings.Contains(r.Header.Get("Accept"), "application/json")
+}
 
-					err = sqlDB.Ping()
-					if err != nil {
-						appendError(fmt.Sprintf("[%s] failed to ping sql database: %v", name, err))
-						return
-					}
-				}(gormDB)
-			}
-		}
+func writeReport(w http.ResponseWriter, r *http.Request, opt *ProbeOptions, report *Report) {
+	code := http.StatusOK
+	if report.Status == StatusDown {
+		code = http.StatusServiceUnavailable
+	}
 
-		// Check redis db connection
-		if len(service.RedisClients()) > 0 {
-			for name, redisClient := range service.RedisClients() {
-				name := name
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "no-cache")
 
-				wg.Add(1)
+	if wantsJSON(r, opt) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(report)
+		return
+	}
 
-				go func(redisClient *redis.Client) {
-					defer wg.Done()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(code)
 
-					statusCMD := redisClient.Ping(ctx)
-					if err := statusCMD.Err(); err != nil {
-						appendError(fmt.Sprintf("[%s] failed to ping redis: %v", name, err))
-						return
-					}
-				}(redisClient)
-			}
+	for _, res := range report.Checks {
+		if res.Status != StatusUp {
+			fmt.Fprintf(w, "[%s] %s check failed (%s): %s\n", res.Name, res.Kind, res.Severity, res.Error)
 		}
+	}
 
-		// check external services
-		for _, extSrv := range cfg.ExternalServices() {
-			if !extSrv.Required() {
-				continue
-			}
+	if report.Message != "" {
+		fmt.Fprintln(w, report.Message)
+	}
+}
 
-			wg.Add(1)
+// dependencies holds the dependency checks of a probe, which are known once the service has been initialized
+type dependencies struct {
+	mu     sync.Mutex
+	checks []*check
+	ready  bool
+}
 
-			// dials concurrently
-			go func(extSrv *config.ServiceInfo) {
-				defer wg.Done()
+// get returns the dependency checks, they are created again on every call until Init has opened the connections
+func (d *dependencies) get(service *micro.Service, ttl time.Duration) []*check {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
-				cc, err := service.DialExternalService(ctx, extSrv.Name())
-				if err != nil {
-					appendError(fmt.Sprintf("failed to connect to %s service: %v", extSrv.Name(), err))
-				} else {
-					defer cc.Close()
-				}
-			}(extSrv)
-		}
+	if d.ready {
+		return d.checks[:len(d.checks):len(d.checks)]
+	}
+
+	checks := dependencyChecks(service, ttl)
+	if service.State() >= micro.StateMigrating {
+		d.checks, d.ready = checks, true
+	}
 
-		// wait for all dials and pings to complete
-		wg.Wait()
+	return checks[:len(checks):len(checks)]
+}
 
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		w.Header().Set("X-Content-Type-Options", "nosniff")
-		w.WriteHeader(http.StatusOK)
+// dependencyChecks creates checks that ping databases and check connections to external services.
+// Failing dependencies are always critical, they are run by readiness and startup probes.
+func dependencyChecks(service *micro.Service, ttl time.Duration) []*check {
+	checks := make([]*check, 0)
+
+	add := func(name, kind string, fn func(ctx context.Context) error) {
+		checks = append(checks, &check{
+			name:     name,
+			kind:     kind,
+			severity: SeverityCritical,
+			ttl:      ttl,
+			fn:       fn,
+		})
+	}
 
-		// Check errors from external components
-		if len(errs) != 0 {
-			for _, err := range errs {
-				fmt.Fprintln(w, err)
+	// Check sql db connection
+	for name, sqlDB := range service.SQLDBs() {
+		add(name, "sql", sqlDB.PingContext)
+	}
+
+	// Check gorm db connection
+	for name, gormDB := range service.GormDBs() {
+		gormDB := gormDB
+		add(name, "gorm", func(ctx context.Context) error {
+			sqlDB, err := gormDB.DB()
+			if err != nil {
+				return fmt.Errorf("failed to get sql database from gorm: %v", err)
 			}
-			return
+			return sqlDB.PingContext(ctx)
+		})
+	}
+
+	// Check redis db connection
+	for name, redisClient := range service.RedisClients() {
+		redisClient := redisClient
+		add(name, "redis", func(ctx context.Context) error {
+			return redisClient.Ping(ctx).Err()
+		})
+	}
+
+	// check external services using the connections opened by the service
+	for _, extSrv := range service.Config().ExternalServices() {
+		if !extSrv.Required() {
+			continue
 		}
 
-		fmt.Fprintln(w, opt.successMsg)
+		name := extSrv.Name()
+		add(name, "service", func(ctx context.Context) error {
+			cc, err := service.ExternalServiceConn(name)
+			if err != nil {
+				return err
+			}
+			return conn.WaitForConnReady(ctx, cc)
+		})
 	}
+
+	return checks
 }
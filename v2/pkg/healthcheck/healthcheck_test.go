@@ -0,0 +1,161 @@
+package healthcheck
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gidyon/micro/v2"
+	"github.com/gidyon/micro/v2/internal/testutil"
+)
+
+func newTestService(t *testing.T) *micro.Service {
+	t.Helper()
+
+	cfg, err := testutil.NewBuilder(t).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := micro.NewService(context.Background(), cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return service
+}
+
+// runTestService runs the service until the test completes, returning once it is serving
+func runTestService(t *testing.T, service *micro.Service) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	err := service.Init(ctx)
+	if err != nil {
+		cancel()
+		t.Fatal(err)
+	}
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- service.Run(ctx)
+	}()
+
+	t.Cleanup(func() {
+		cancel()
+
+		select {
+		case err := <-runErr:
+			if err != nil {
+				t.Errorf("Run() error = %v", err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Error("service did not stop")
+		}
+	})
+
+	for start := time.Now(); service.State() != micro.StateServing; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("service not serving")
+		}
+	}
+}
+
+func TestLivenessIgnoresDependencies(t *testing.T) {
+	service := newTestService(t)
+
+	// Nothing listens on port 1 so pinging the database fails
+	db, err := sql.Open("pgx", "postgres://test@127.0.0.1:1/test?connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	service.SQLDBs()["orders"] = db
+
+	probe := RegisterProbe(&ProbeOptions{Service: service, Type: ProbeLiveNess, Registry: NewRegistry()})
+
+	w := httptest.NewRecorder()
+	probe(w, httptest.NewRequest(http.MethodGet, "/livez", nil))
+
+	if w.Code != http.StatusOK {
+		t.Errorf("liveness probe status = %d with failing dependency, want 200\n%s", w.Code, w.Body)
+	}
+
+	if len(dependencyChecks(service, 0)) != 1 {
+		t.Error("dependency check for the database not created")
+	}
+}
+
+func TestDependenciesAfterInit(t *testing.T) {
+	service := newTestService(t)
+	deps := &dependencies{}
+
+	if got := deps.get(service, 0); len(got) != 0 {
+		t.Fatalf("dependency checks = %d, want 0", len(got))
+	}
+
+	// Connections opened after the first probe are still checked
+	service.SQLDBs()["orders"] = &sql.DB{}
+
+	if got := deps.get(service, 0); len(got) != 1 {
+		t.Errorf("dependency checks = %d after the database was opened, want 1", len(got))
+	}
+}
+
+func TestReadinessFailingCheck(t *testing.T) {
+	service := newTestService(t)
+
+	registry := NewRegistry()
+	err := registry.Register(&CheckerOptions{
+		Checker: NewChecker("queue", func(context.Context) error {
+			return errors.New("queue unreachable")
+		}),
+		Kind: "queue",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	probe := RegisterProbe(&ProbeOptions{Service: service, Type: ProbeReadiness, Registry: registry})
+
+	runTestService(t, service)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/readyz", nil)
+	r.Header.Set("Accept", "application/json")
+	probe(w, r)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("readiness probe status = %d with failing critical check, want 503\n%s", w.Code, w.Body)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+
+	report := &Report{}
+	err = json.Unmarshal(w.Body.Bytes(), report)
+	if err != nil {
+		t.Fatalf("failed to decode report: %v\n%s", err, w.Body)
+	}
+
+	if report.Status != StatusDown || report.Probe != ProbeReadiness || report.State != micro.StateServing.String() {
+		t.Errorf("report = %+v, want status down from serving readiness probe", report)
+	}
+	if len(report.Checks) != 1 {
+		t.Fatalf("report checks = %d, want 1", len(report.Checks))
+	}
+
+	want := &CheckResult{Name: "queue", Kind: "queue", Severity: SeverityCritical, Status: StatusDown, Error: "queue unreachable"}
+	got := report.Checks[0]
+	if got.Name != want.Name || got.Kind != want.Kind || got.Severity != want.Severity || got.Status != want.Status || got.Error != want.Error {
+		t.Errorf("check result = %+v, want %+v", got, want)
+	}
+}
@@ -78,7 +78,8 @@ type Options struct {
 	AdminsGroup      []string
 }
 
-// NewAPI creates a jwt authentication and authorization API using HS256 algorithm
+// NewAPI creates a jwt authentication and authorization API. Tokens are signed using HS256 algorithm unless
+// SigningMethod is set, tokens signed with OtherSigningKeys are also accepted e.g during key rotation.
 func NewAPI(opt *Options) (API, error) {
 
 	// Validation
@@ -95,7 +96,9 @@ func NewAPI(opt *Options) (API, error) {
 		opt.AdminsGroup = DefaultAdminGroups()
 	}
 
-	opt.SigningMethod = jwt.SigningMethodHS256
+	if opt.SigningMethod == nil {
+		opt.SigningMethod = jwt.SigningMethodHS256
+	}
 
 	optVal := *opt
 
@@ -363,13 +366,23 @@ func (api *authAPI) ParseToken(tokenString string) (claims *Claims, err error) {
 		}
 	}()
 
-	token, err := jwt.ParseWithClaims(
-		tokenString,
-		&Claims{},
-		func(token *jwt.Token) (interface{}, error) {
-			return api.SigningKey, nil
-		},
-	)
+	var token *jwt.Token
+	for _, key := range append([][]byte{api.SigningKey}, api.OtherSigningKeys...) {
+		token, err = jwt.ParseWithClaims(
+			tokenString,
+			&Claims{},
+			func(token *jwt.Token) (interface{}, error) {
+				if token.Method.Alg() != api.SigningMethod.Alg() {
+					return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+				}
+				return key, nil
+			},
+		)
+		// Other keys are only tried when the signature does not match
+		if verr, ok := err.(*jwt.ValidationError); !ok || verr.Errors&jwt.ValidationErrorSignatureInvalid == 0 {
+			break
+		}
+	}
 	if err != nil {
 		return nil, status.Errorf(
 			codes.Unauthenticated, "failed to parse token with claims: %v", err,
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+func TestParseTokenSigningKeys(t *testing.T) {
+	api, err := NewAPI(&Options{
+		SigningMethod:    jwt.SigningMethodHS384,
+		SigningKey:       []byte("new-key"),
+		OtherSigningKeys: [][]byte{[]byte("old-key")},
+		Issuer:           "orders",
+		Audience:         "accounts",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := func(method jwt.SigningMethod, key string, expires time.Time) string {
+		token, err := jwt.NewWithClaims(method, &Claims{
+			Payload:        &Payload{ID: "1"},
+			StandardClaims: jwt.StandardClaims{ExpiresAt: expires.Unix()},
+		}).SignedString([]byte(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	current, err := api.GenToken(context.Background(), &Payload{ID: "1"}, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		valid   = time.Now().Add(time.Minute)
+		expired = time.Now().Add(-time.Minute)
+	)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "signing key", token: current},
+		{name: "other signing key", token: signed(jwt.SigningMethodHS384, "old-key", valid)},
+		{name: "unknown key", token: signed(jwt.SigningMethodHS384, "unknown-key", valid), wantErr: true},
+		{name: "other signing method", token: signed(jwt.SigningMethodHS256, "new-key", valid), wantErr: true},
+		{name: "expired with other signing key", token: signed(jwt.SigningMethodHS384, "old-key", expired), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := api.GetClaimsFromJwt(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetClaimsFromJwt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewAPIDefaultSigningMethod(t *testing.T) {
+	api, err := NewAPI(&Options{SigningKey: []byte("key"), Issuer: "orders", Audience: "accounts"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := api.GenToken(context.Background(), &Payload{ID: "1"}, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, _, err := new(jwt.Parser).ParseUnverified(token, &Claims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+		t.Errorf("signing method = %s, want HS256 when unset", parsed.Method.Alg())
+	}
+}
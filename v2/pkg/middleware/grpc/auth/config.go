@@ -0,0 +1,29 @@
+package auth
+
+import (
+	"github.com/gidyon/micro/v2/pkg/config"
+	"github.com/gidyon/micro/v2/utils/errs"
+	"github.com/golang-jwt/jwt"
+)
+
+// NewAPIFromConfig creates a jwt authentication and authorization API using the auth section of config.
+// Signing keys read from files or secret providers are resolved when the config is loaded.
+func NewAPIFromConfig(cfg *config.Config, adminGroups ...string) (API, error) {
+	if cfg == nil {
+		return nil, errs.MissingField("config")
+	}
+
+	opt := cfg.Auth()
+	if !opt.Enabled() {
+		return nil, errs.MissingField("auth signing key")
+	}
+
+	return NewAPI(&Options{
+		SigningMethod:    jwt.GetSigningMethod(opt.SigningMethod()),
+		SigningKey:       opt.SigningKey(),
+		OtherSigningKeys: opt.OtherSigningKeys(),
+		Issuer:           opt.Issuer(),
+		Audience:         opt.Audience(),
+		AdminsGroup:      adminGroups,
+	})
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gidyon/micro/v2/pkg/config"
+)
+
+const authTestConfig = `
+serviceName: orders
+httpPort: 8080
+grpcPort: 8081
+security:
+  insecure: true
+auth:
+  issuer: orders
+  audience: accounts
+  signingMethod: HS384
+  signingKey: new-key
+  otherSigningKeys:
+    - old-key
+`
+
+func TestNewAPIFromConfig(t *testing.T) {
+	cfg, err := config.Load(&config.Options{File: "config.yml", Reader: strings.NewReader(authTestConfig)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	api, err := NewAPIFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := api.GenToken(context.Background(), &Payload{ID: "1"}, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := api.GetClaimsFromJwt(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Issuer != "orders" || claims.Audience != "accounts" {
+		t.Errorf("claims issuer = %q, audience = %q, want values from config", claims.Issuer, claims.Audience)
+	}
+
+	// Tokens signed before the key was rotated are still accepted
+	old, err := api.GenTokenUsingKey(context.Background(), &Claims{Payload: &Payload{ID: "1"}}, time.Now().Add(time.Minute), []byte("old-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = api.GetClaimsFromJwt(old); err != nil {
+		t.Errorf("token signed with other signing key rejected: %v", err)
+	}
+
+	other, err := api.GenTokenUsingKey(context.Background(), &Claims{Payload: &Payload{ID: "1"}}, time.Now().Add(time.Minute), []byte("unknown-key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = api.GetClaimsFromJwt(other); err == nil {
+		t.Error("token signed with unknown key accepted")
+	}
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"context"
+	"crypto/x509"
+
+	"github.com/gidyon/micro/v2/utils/tlsutil"
+	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// PeerCertificateKey is the metadata key carrying the verified client certificate of a request forwarded by a gateway
+const PeerCertificateKey = "x-peer-certificate-bin"
+
+func peerIdentityContext(ctx context.Context, forwarded func(*peer.Peer) bool) context.Context {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ctx
+	}
+
+	// Only trusted peers may forward the identity of their callers
+	if forwarded != nil && forwarded(p) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		vals := md.Get(PeerCertificateKey)
+		if len(vals) != 1 {
+			return ctx
+		}
+		cert, err := x509.ParseCertificate([]byte(vals[0]))
+		if err != nil {
+			return ctx
+		}
+		return tlsutil.NewContextWithPeerIdentity(ctx, tlsutil.PeerIdentityFromCertificate(cert))
+	}
+
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return ctx
+	}
+	if identity := tlsutil.PeerIdentityFromConnState(&tlsInfo.State); identity != nil {
+		return tlsutil.NewContextWithPeerIdentity(ctx, identity)
+	}
+	return ctx
+}
+
+// AddPeerIdentity adds the identity from a verified client certificate to the handler context.
+// Requests from peers for which forwarded returns true, such as an in-process gateway, carry the identity of
+// their caller in metadata instead. Forwarded identities from any other peer are ignored.
+// Use tlsutil.PeerIdentityFromContext to retrieve it.
+func AddPeerIdentity(forwarded func(*peer.Peer) bool) ([]grpc.UnaryServerInterceptor, []grpc.StreamServerInterceptor) {
+	return []grpc.UnaryServerInterceptor{
+		func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			return handler(peerIdentityContext(ctx, forwarded), req)
+		},
+	}, []grpc.StreamServerInterceptor{
+		func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			wrapped := grpc_middleware.WrapServerStream(ss)
+			wrapped.WrappedContext = peerIdentityContext(ss.Context(), forwarded)
+			return handler(srv, wrapped)
+		},
+	}
+}
+
+// forwardedIdentityContext replaces any peer certificate in the outgoing metadata with the one from the peer identity in ctx
+func forwardedIdentityContext(ctx context.Context) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	md.Delete(PeerCertificateKey)
+
+	if identity, ok := tlsutil.PeerIdentityFromContext(ctx); ok && identity.Certificate != nil {
+		md.Set(PeerCertificateKey, string(identity.Certificate.Raw))
+	}
+
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// ForwardPeerIdentity forwards the peer identity in the request context, e.g added by the http AddPeerIdentity
+// middleware, to the server in metadata. Peer certificates set by the caller in metadata are dropped.
+func ForwardPeerIdentity() ([]grpc.UnaryClientInterceptor, []grpc.StreamClientInterceptor) {
+	return []grpc.UnaryClientInterceptor{
+		func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			return invoker(forwardedIdentityContext(ctx), method, req, reply, cc, opts...)
+		},
+	}, []grpc.StreamClientInterceptor{
+		func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+			return streamer(forwardedIdentityContext(ctx), desc, cc, method, opts...)
+		},
+	}
+}
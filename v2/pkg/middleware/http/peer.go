@@ -0,0 +1,18 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gidyon/micro/v2/utils/tlsutil"
+)
+
+// AddPeerIdentity adds the identity from a verified client certificate to the request context.
+// Use tlsutil.PeerIdentityFromContext to retrieve it.
+func AddPeerIdentity(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if identity := tlsutil.PeerIdentityFromConnState(r.TLS); identity != nil {
+			r = r.WithContext(tlsutil.NewContextWithPeerIdentity(r.Context(), identity))
+		}
+		h.ServeHTTP(w, r)
+	})
+}
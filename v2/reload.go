@@ -0,0 +1,75 @@
+package micro
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gidyon/micro/v2/pkg/config"
+	"github.com/gidyon/micro/v2/pkg/conn"
+	http_middleware "github.com/gidyon/micro/v2/pkg/middleware/http"
+	"github.com/rs/zerolog"
+)
+
+// levelSetter is implemented by loggers whose level can be changed, such as the default logger
+type levelSetter interface {
+	SetLevel(level zerolog.Level)
+}
+
+// applyConfigChanges updates the log level and database pool settings when config is reloaded.
+// Pools are updated once the service is serving, pool options set on the service take precedence.
+func (service *Service) applyConfigChanges(old, new *config.Config) {
+	if level := logLevel(new); logLevel(old) != level {
+		if logger, ok := service.logger.(levelSetter); ok {
+			logger.SetLevel(level)
+			service.logger.Infof("log level changed to %s", level)
+		}
+	}
+
+	if service.State() != StateServing {
+		return
+	}
+
+	service.logger.Info("config reloaded")
+
+	for _, db := range new.Databases() {
+		if db.Type != config.SQLDBType || !db.Required() {
+			continue
+		}
+
+		name := db.Metadata().Name()
+
+		sqlDB, ok := service.sqlDBs[name]
+		if !ok {
+			continue
+		}
+
+		poolOptions, ok := service.dbPoolOptions[name]
+		if !ok {
+			poolOptions = &conn.DBConnPoolOptions{}
+		}
+
+		poolSettings := db.PoolSettings()
+
+		if poolOptions.MaxOpenConns == 0 && poolSettings.MaxOpenConns() != 0 {
+			sqlDB.SetMaxOpenConns(int(poolSettings.MaxOpenConns()))
+		}
+		if poolOptions.MaxIdleConns == 0 && poolSettings.MaxIdleConns() != 0 {
+			sqlDB.SetMaxIdleConns(int(poolSettings.MaxIdleConns()))
+		}
+		if poolOptions.MaxLifetime == 0 && poolSettings.MaxConnLifetimeSeconds() != 0 {
+			sqlDB.SetConnMaxLifetime(time.Duration(poolSettings.MaxConnLifetimeSeconds()) * time.Second)
+		}
+	}
+}
+
+// supportCORS adds CORS headers if enabled in config, which can change when config is reloaded
+func (service *Service) supportCORS(h http.Handler) http.Handler {
+	cors := http_middleware.SupportCORS(h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if service.cfg.HttpOptions().CorsEnabled() {
+			cors.ServeHTTP(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,61 @@
+package micro
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gidyon/micro/v2/internal/testutil"
+	"github.com/gidyon/micro/v2/pkg/config"
+	"github.com/rs/zerolog"
+)
+
+func TestApplyLogLevel(t *testing.T) {
+	newConfig := func(level zerolog.Level) *config.Config {
+		cfg, err := testutil.NewBuilder(t).LogLevel(int(level)).Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cfg
+	}
+
+	cfg := newConfig(zerolog.WarnLevel)
+
+	service, err := NewService(context.Background(), cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	l := service.logger.(*logger)
+	l.log = zerolog.New(&buf)
+
+	service.logger.Info("before reload")
+	if buf.Len() != 0 {
+		t.Errorf("info message logged at warn level from config: %s", buf.String())
+	}
+
+	service.applyConfigChanges(cfg, newConfig(zerolog.InfoLevel))
+
+	service.logger.Info("after reload")
+	if !strings.Contains(buf.String(), "after reload") {
+		t.Errorf("info message not logged after log level was reloaded: %q", buf.String())
+	}
+}
+
+func TestDefaultLogLevel(t *testing.T) {
+	cfg, err := testutil.NewBuilder(t).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := NewService(context.Background(), cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if level := zerolog.Level(service.logger.(*logger).level); level != zerolog.TraceLevel {
+		t.Errorf("log level = %s when unset in config, want %s", level, zerolog.TraceLevel)
+	}
+}
@@ -4,23 +4,28 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
-	"log"
 	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/insecure"
 
 	"github.com/gidyon/micro/v2/pkg/conn"
+	grpc_mw "github.com/gidyon/micro/v2/pkg/middleware/grpc"
 	http_middleware "github.com/gidyon/micro/v2/pkg/middleware/http"
-	"github.com/gidyon/micro/v2/utils/tlsutil"
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
 	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 
 	"google.golang.org/grpc"
@@ -36,44 +41,90 @@ func handleErrs(errs ...error) {
 }
 
 // initializes service without starting it.
-func (service *Service) init(ctx context.Context) {
+func (service *Service) init(ctx context.Context) error {
+	steps := []func(context.Context) error{
+		service.initTLS,
+		service.openSQLDBConnections,
+		service.openRedisConnections,
+		service.openExternalConnections,
+		service.waitForDependencies,
+		service.initGRPC,
+	}
+
+	err := service.runHooks(ctx, PhasePreInit)
+	if err != nil {
+		return err
+	}
+
+	for _, step := range steps {
+		if err := ctx.Err(); err != nil {
+			return errors.Wrap(err, "service initialization cancelled")
+		}
+		if err := step(ctx); err != nil {
+			return err
+		}
+	}
+
+	return service.runHooks(ctx, PhasePostInit)
+}
+
+// Init opens connections to databases and external services and initializes the gRPC server without starting it.
+// Only the first call does the initialization, subsequent calls return its result.
+func (service *Service) Init(ctx context.Context) error {
 	service.initOnceFn.Do(func() {
-		handleErrs(
-			service.openSQLDBConnections(ctx),
-			service.openRedisConnections(ctx),
-			service.openExternalConnections(ctx),
-			service.initGRPC(ctx),
-		)
+		service.setState(StateInitializing)
+		service.initErr = service.init(ctx)
 	})
+	return service.initErr
 }
 
-// Initialize initializes service without starting it.
+// Initialize initializes service without starting it. It panics if initialization fails.
 func (service *Service) Initialize(ctx context.Context) {
-	service.init(ctx)
+	handleErrs(service.Init(ctx))
+}
+
+// Run initializes the service if not yet initialized, afterwards starting grpc and http server to serve requests.
+// It blocks until the service receives SIGINT or SIGTERM or ctx is cancelled, then shuts down gracefully.
+// The returned error is nil if the service was stopped cleanly.
+func (service *Service) Run(ctx context.Context) error {
+	err := service.Init(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to initialize service")
+	}
+	return service.run(ctx)
 }
 
 // Start opens connection to databases and external services, afterwards starting grpc and http server to serve requests.
+// It blocks until the service receives SIGINT or SIGTERM or ctx is cancelled, then shuts down gracefully.
+// Start panics on any error, use Init and Run to handle errors.
 func (service *Service) Start(ctx context.Context, initFn func() error) {
-	service.init(ctx)
-	handleErrs(initFn(), service.run(ctx))
+	handleErrs(service.Init(ctx))
+	handleErrs(initFn())
+	handleErrs(service.Run(ctx))
 }
 
-// starts the servers
+// starts the servers and blocks until the service is asked to stop
 func (service *Service) run(ctx context.Context) error {
-	fn := func() error {
+	fn := func() (err error) {
+		var httpServer, adminServer *http.Server
+
+		// Servers, connections and registered shutdown functions are released however the service stops
 		defer func() {
-			var err error
-			for _, shutdown := range service.shutdowns {
-				err = shutdown()
-				if err != nil {
-					service.logger.Errorln(err)
-				}
-			}
+			err = multierr.Append(err, service.shutdown(httpServer, adminServer))
 		}()
 
+		err = service.runHooks(ctx, PhaseStart)
+		if err != nil {
+			service.logger.Errorf("failed to start service, shutting down: %v", err)
+			return err
+		}
+
 		// Apply optional middlewares
-		if service.cfg.HttpOptions().CorsEnabled() {
-			service.httpMiddlewares = append(service.httpMiddlewares, http_middleware.SupportCORS)
+		service.httpMiddlewares = append(service.httpMiddlewares, service.supportCORS)
+		if service.tlsConfig != nil && service.tlsConfig.ClientAuth != tls.NoClientCert {
+			service.httpMiddlewares = append(
+				[]http_middleware.Middleware{http_middleware.AddPeerIdentity}, service.httpMiddlewares...,
+			)
 		}
 
 		// Handles grpc gateway apis
@@ -82,93 +133,122 @@ func (service *Service) run(ctx context.Context) error {
 		// Apply any middlewares to the handler
 		handler := http_middleware.Apply(service.Handler(), service.httpMiddlewares...)
 
+		h2Server := &http2.Server{}
+
 		var ghandler http.Handler
 
-		// add grpc handler if TLS is enabled on service, will use same port
-		if service.cfg.ServiceTLSEnabled() {
-			ghandler = grpcHandlerFunc(service.GRPCServer(), handler)
-		} else {
+		// add grpc handler if TLS or h2c is enabled on service, will use same port
+		switch {
+		case service.cfg.ServiceTLSEnabled():
+			ghandler = service.grpcHandlerFunc(handler)
+		case service.cfg.SinglePort():
+			ghandler = h2c.NewHandler(service.grpcHandlerFunc(handler), h2Server)
+		default:
 			ghandler = handler
 		}
 
-		httpServer := &http.Server{
+		httpServer = &http.Server{
 			Addr:         fmt.Sprintf(":%d", service.cfg.ServicePort()),
 			Handler:      ghandler,
 			ReadTimeout:  time.Duration(service.httpServerReadTimeout) * time.Second,
 			WriteTimeout: time.Duration(service.httpServerWriteTimeout) * time.Second,
 		}
 
-		// Graceful shutdown of server
-		c := make(chan os.Signal, 1)
-		signal.Notify(c, os.Interrupt)
-		go func() {
-			for range c {
-				service.logger.Warning("shutting down service ...")
-				service.gRPCServer.Stop()
-				log.Fatalln(httpServer.Shutdown(ctx))
-
-				<-ctx.Done()
+		// Lets shutdown send GOAWAY frames to h2c connections which are hijacked from the http server
+		if !service.cfg.ServiceTLSEnabled() && service.cfg.SinglePort() {
+			err = http2.ConfigureServer(httpServer, h2Server)
+			if err != nil {
+				return errors.Wrap(err, "failed to configure h2c server")
 			}
-		}()
+		}
 
 		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", service.cfg.ServicePort()))
 		if err != nil {
 			return errors.Wrap(err, "failed to create TCP listener for http server")
 		}
-		defer lis.Close()
-
-		logMsgFn := func() {
-			if !service.cfg.ServiceTLSEnabled() {
-				service.logger.Infof(
-					"<GRPC> running on port %d (insecure), <REST> server running on port %d (insecure)",
-					service.cfg.GRPCPort(), service.cfg.ServicePort(),
-				)
-			} else {
-				service.logger.Infof(
-					"<gRPC> and <REST> server running on same port %d (secure)",
-					service.cfg.ServicePort(),
-				)
-			}
+
+		if service.cfg.ServiceTLSEnabled() {
+			lis = tls.NewListener(lis, service.tlsConfig)
 		}
 
-		logMsgFn()
+		// Errors from servers that stopped on their own
+		errChan := make(chan error, 3)
 
-		if !service.cfg.ServiceTLSEnabled() {
+		if !service.cfg.SinglePort() {
 			glis, err := net.Listen("tcp", fmt.Sprintf(":%d", service.cfg.GRPCPort()))
 			if err != nil {
+				lis.Close()
 				return errors.Wrap(err, "failed to create TCP listener for gRPC server")
 			}
-			defer glis.Close()
 
 			// Note: The call to serve grpc must be inside a goroutine; don't do [go service.gRPCServer.Serve(glis)]
 			go func() {
-				err := service.gRPCServer.Serve(glis)
-				if err != nil {
-					service.logger.Errorln(err)
-				}
+				errChan <- errors.Wrap(service.gRPCServer.Serve(glis), "gRPC server stopped")
+			}()
+
+			service.logger.Infof(
+				"<GRPC> running on port %d (insecure), <REST> server running on port %d (insecure)",
+				service.cfg.GRPCPort(), service.cfg.ServicePort(),
+			)
+		} else if service.loopback != nil {
+			go func() {
+				errChan <- errors.Wrap(service.gRPCServer.Serve(service.loopback), "gRPC gateway loopback stopped")
 			}()
 
-			// Serve http insecurely
-			return httpServer.Serve(lis)
+			service.logger.Infof(
+				"<gRPC> and <REST> server running on same port %d (secure, client certificates verified)",
+				service.cfg.ServicePort(),
+			)
+		} else if !service.cfg.ServiceTLSEnabled() {
+			service.logger.Infof(
+				"<gRPC> and <REST> server running on same port %d (insecure h2c)",
+				service.cfg.ServicePort(),
+			)
+		} else {
+			service.logger.Infof(
+				"<gRPC> and <REST> server running on same port %d (secure)",
+				service.cfg.ServicePort(),
+			)
 		}
 
-		cert, certPool, err := tlsutil.GetCert(service.Config().ServiceTLSCertFile(), service.Config().ServiceTLSKeyFile())
-		if err != nil {
-			return err
+		go func() {
+			err := httpServer.Serve(lis)
+			if errors.Is(err, http.ErrServerClosed) {
+				err = nil
+			}
+			errChan <- errors.Wrap(err, "http server stopped")
+		}()
+
+		// Wait for termination signal, context cancellation or server failure
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(sigChan)
+
+		// The service is reported as serving once ready hooks such as migrations complete
+		var serveErr error
+		adminServer, serveErr = service.startAdminServer(errChan)
+		if serveErr == nil {
+			serveErr = service.runHooks(ctx, PhaseReady)
+		}
+		if serveErr == nil {
+			service.setState(StateServing)
+			service.setServingStatus(healthpb.HealthCheckResponse_SERVING)
 		}
 
-		tlsConfig := &tls.Config{
-			NextProtos:         []string{"h2", "http/1.1", "http/1.2"},
-			MinVersion:         tls.VersionTLS10,
-			MaxVersion:         tls.VersionTLS13,
-			ClientAuth:         tls.VerifyClientCertIfGiven,
-			ClientCAs:          certPool,
-			Certificates:       []tls.Certificate{*cert},
-			InsecureSkipVerify: true,
+		if serveErr != nil {
+			service.logger.Errorf("failed to start service, shutting down: %v", serveErr)
+		} else {
+			select {
+			case sig := <-sigChan:
+				service.logger.Warningf("received %s signal, shutting down service ...", sig)
+			case <-ctx.Done():
+				service.logger.Warning("context cancelled, shutting down service ...")
+			case serveErr = <-errChan:
+				service.logger.Errorf("server stopped unexpectedly, shutting down service: %v", serveErr)
+			}
 		}
 
-		// Serve tls
-		return httpServer.Serve(tls.NewListener(lis, tlsConfig))
+		return serveErr
 	}
 
 	var err error
@@ -179,12 +259,102 @@ func (service *Service) run(ctx context.Context) error {
 	return err
 }
 
-// grpcHandlerFunc returns an http.Handler that delegates to grpcServer on incoming gRPC
+// shutdown drains and stops the servers, then runs the registered shutdown functions in reverse order.
+// The admin server is stopped last so that operational endpoints remain available while draining.
+func (service *Service) shutdown(httpServer, adminServer *http.Server) error {
+	// Readiness probes should start failing so that no new traffic is routed to the service
+	service.setState(StateDraining)
+	service.healthServer.Shutdown()
+
+	errs := service.runHooks(context.Background(), PhasePreShutdown)
+
+	if service.shutdownDrainPeriod > 0 {
+		service.logger.Infof("waiting %s for in-flight requests to drain", service.shutdownDrainPeriod)
+		time.Sleep(service.shutdownDrainPeriod)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), service.shutdownTimeout)
+	defer cancel()
+
+	// gRPC is stopped gracefully first, requests forwarded by the gateway are in-flight RPCs so they complete
+	// before the http server is shut down. The http server is nil if the service failed before it was created.
+	service.stopGRPC(ctx)
+
+	if httpServer != nil {
+		err := httpServer.Shutdown(ctx)
+		if err != nil {
+			errs = multierr.Append(errs, errors.Wrap(err, "failed to shutdown http server"))
+		}
+	}
+
+	if adminServer != nil {
+		err := adminServer.Shutdown(ctx)
+		if err != nil {
+			errs = multierr.Append(errs, errors.Wrap(err, "failed to shutdown admin server"))
+		}
+	}
+
+	errs = multierr.Append(errs, service.runHooks(context.Background(), PhasePostShutdown))
+
+	for i := len(service.shutdowns) - 1; i >= 0; i-- {
+		errs = multierr.Append(errs, service.shutdowns[i]())
+	}
+
+	service.setState(StateStopped)
+
+	if errs != nil {
+		service.logger.Errorf("service stopped with errors: %v", errs)
+		return errs
+	}
+
+	service.logger.Info("service stopped")
+
+	return nil
+}
+
+// stopGRPC stops the gRPC server gracefully, forcing it to stop if ctx expires before pending RPCs finish
+func (service *Service) stopGRPC(ctx context.Context) {
+	// gRPC served through the http handler cannot be drained using GracefulStop
+	if service.cfg.SinglePort() {
+		ticker := time.NewTicker(100 * time.Millisecond)
+		defer ticker.Stop()
+
+		for atomic.LoadInt64(&service.inflightGRPC) > 0 {
+			select {
+			case <-ctx.Done():
+				service.logger.Warning("timed out waiting for gRPC requests to complete")
+				service.gRPCServer.Stop()
+				return
+			case <-ticker.C:
+			}
+		}
+
+		service.gRPCServer.Stop()
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		service.gRPCServer.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		service.logger.Warning("timed out waiting for gRPC server to stop gracefully")
+		service.gRPCServer.Stop()
+	}
+}
+
+// grpcHandlerFunc returns an http.Handler that delegates to the service gRPC server on incoming gRPC
 // connections or otherHandler otherwise.
-func grpcHandlerFunc(grpcServer *grpc.Server, otherHandler http.Handler) http.Handler {
+func (service *Service) grpcHandlerFunc(otherHandler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.ProtoMajor == 2 && strings.Contains(r.Header.Get("Content-Type"), "application/grpc") {
-			grpcServer.ServeHTTP(w, r)
+			atomic.AddInt64(&service.inflightGRPC, 1)
+			defer atomic.AddInt64(&service.inflightGRPC, -1)
+			service.gRPCServer.ServeHTTP(w, r)
 		} else {
 			otherHandler.ServeHTTP(w, r)
 		}
@@ -209,17 +379,33 @@ func (service *Service) initGRPC(ctx context.Context) error {
 		err   error
 	)
 
-	if service.cfg.ServiceTLSEnabled() {
-		creds, err := credentials.NewClientTLSFromFile(
+	// When client certificates are verified the gateway reaches the gRPC server in memory, forwarding the
+	// identity of the REST caller, so that the service certificate is never presented on its behalf
+	peerAuth := service.tlsConfig != nil && service.tlsConfig.ClientAuth != tls.NoClientCert
+
+	switch {
+	case peerAuth:
+		service.loopback = newLoopbackListener()
+		service.dialOptions = append(service.dialOptions,
+			grpc.WithContextDialer(service.loopback.Dial),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		)
+	case service.cfg.ServiceTLSEnabled():
+		tlsConfig, err := service.clientTLSConfig(
 			service.cfg.ServiceTLSCertFile(), service.cfg.ServiceTLSServerName())
 		if err != nil {
 			return errors.Wrapf(err,
 				"failed to create tls config for %s service", service.cfg.ServiceTLSServerName())
 		}
-		service.dialOptions = append(service.dialOptions, grpc.WithTransportCredentials(creds))
-		gPort = service.cfg.HTTPort()
-	} else {
+		tlsConfig.GetClientCertificate = nil
+		service.dialOptions = append(service.dialOptions, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	default:
 		service.dialOptions = append(service.dialOptions, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+
+	if service.cfg.SinglePort() {
+		gPort = service.cfg.ServicePort()
+	} else {
 		gPort = service.cfg.GRPCPort()
 	}
 
@@ -243,6 +429,13 @@ func (service *Service) initGRPC(ctx context.Context) error {
 	streamClientInterceptors := make([]grpc.StreamClientInterceptor, 0)
 	streamClientInterceptors = append(streamClientInterceptors, service.streamClientInterceptors...)
 
+	// Forwarded identity is set last so that other interceptors cannot change it
+	if peerAuth {
+		forwardUnary, forwardStream := grpc_mw.ForwardPeerIdentity()
+		unaryClientInterceptors = append(unaryClientInterceptors, forwardUnary...)
+		streamClientInterceptors = append(streamClientInterceptors, forwardStream...)
+	}
+
 	// Add inteceptors as dial option
 	service.dialOptions = append(service.dialOptions, []grpc.DialOption{
 		grpc.WithUnaryInterceptor(
@@ -254,7 +447,7 @@ func (service *Service) initGRPC(ctx context.Context) error {
 	}...)
 
 	// client connection to the reverse gateway
-	service.clientConn, err = conn.DialService(context.Background(), &conn.GRPCDialOptions{
+	service.clientConn, err = conn.DialService(ctx, &conn.GRPCDialOptions{
 		ServiceName: "self",
 		Address:     fmt.Sprintf("localhost:%d", gPort),
 		DialOptions: service.dialOptions,
@@ -264,29 +457,38 @@ func (service *Service) initGRPC(ctx context.Context) error {
 		return errors.Wrap(err, "client failed to dial to gRPC server")
 	}
 
+	service.shutdowns = append(service.shutdowns, func() error {
+		return service.clientConn.Close()
+	})
+
 	// ============================= Initialize grpc server =============================
-	// Add transport credentials if secure option is passed
-	if service.cfg.ServiceTLSEnabled() {
-		creds, err := credentials.NewServerTLSFromFile(
-			service.cfg.ServiceTLSCertFile(), service.cfg.ServiceTLSKeyFile())
-		if err != nil {
-			return fmt.Errorf("failed to create grpc server tls credentials: %v", err)
-		}
-		service.serverOptions = append(
-			service.serverOptions, grpc.Creds(creds),
-		)
+	// The server has no transport credentials, with tls enabled gRPC is served through the tls http server
+	// and to the gateway over the in-memory loopback listener
+
+	unaryInterceptors := service.unaryInterceptors
+	streamInterceptors := service.streamInterceptors
+
+	// Verified client identity is made available to handlers
+	if peerAuth {
+		peerUnary, peerStream := grpc_mw.AddPeerIdentity(isLoopbackPeer)
+		unaryInterceptors = append(peerUnary, unaryInterceptors...)
+		streamInterceptors = append(peerStream, streamInterceptors...)
 	}
 
 	// Append interceptors as server options
 	service.serverOptions = append(
-		service.serverOptions, grpc_middleware.WithUnaryServerChain(service.unaryInterceptors...))
+		service.serverOptions, grpc_middleware.WithUnaryServerChain(unaryInterceptors...))
 	service.serverOptions = append(
-		service.serverOptions, grpc_middleware.WithStreamServerChain(service.streamInterceptors...))
+		service.serverOptions, grpc_middleware.WithStreamServerChain(streamInterceptors...))
 
 	service.gRPCServer = grpc.NewServer(service.serverOptions...)
 
 	// register reflection on the gRPC server
 	reflection.Register(service.gRPCServer)
 
+	// register health checking protocol, the server is created with the service so that statuses set before
+	// initialization are kept
+	healthpb.RegisterHealthServer(service.gRPCServer, service.healthServer)
+
 	return nil
 }
@@ -0,0 +1,132 @@
+package micro
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gidyon/micro/v2/internal/testutil"
+	"github.com/gidyon/micro/v2/pkg/config"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func TestShutdownDrainsInflightRequests(t *testing.T) {
+	const (
+		drain   = time.Second
+		latency = drain + 500*time.Millisecond
+	)
+
+	httpPort := testutil.FreePort(t)
+
+	cfg, err := config.NewBuilder("test").
+		Insecure().
+		HTTPort(httpPort).
+		GRPCPort(testutil.FreePort(t)).
+		Set("shutdownDrainSeconds", strconv.Itoa(int(drain.Seconds()))).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := NewService(context.Background(), cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if service.shutdownDrainPeriod != drain {
+		t.Fatalf("drain period = %s, want %s from config", service.shutdownDrainPeriod, drain)
+	}
+
+	started := make(chan struct{}, 2)
+
+	// Requests outlast the drain period so they are still running when the servers are stopped
+	service.AddGRPCUnaryServerInterceptors(func(
+		ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		started <- struct{}{}
+		time.Sleep(latency)
+		return handler(ctx, req)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err = service.Init(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = service.RuntimeMux().HandlePath(http.MethodGet, "/slow", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+		started <- struct{}{}
+		time.Sleep(latency)
+		fmt.Fprint(w, "done")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- service.Run(ctx)
+	}()
+
+	for start := time.Now(); service.State() != StateServing; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("service not serving")
+		}
+	}
+
+	grpcErr := make(chan error, 1)
+	go func() {
+		_, err := healthpb.NewHealthClient(service.ClientConn()).Check(context.Background(), &healthpb.HealthCheckRequest{})
+		grpcErr <- err
+	}()
+
+	httpRes := make(chan string, 1)
+	go func() {
+		res, err := http.Get(fmt.Sprintf("http://localhost:%d/slow", httpPort))
+		if err != nil {
+			httpRes <- err.Error()
+			return
+		}
+		defer res.Body.Close()
+		bs, _ := ioutil.ReadAll(res.Body)
+		httpRes <- string(bs)
+	}()
+
+	<-started
+	<-started
+
+	cancel()
+
+	for start := time.Now(); service.State() != StateDraining; time.Sleep(10 * time.Millisecond) {
+		if time.Since(start) > time.Second {
+			t.Fatalf("State() = %s after context was cancelled, want %s", service.State(), StateDraining)
+		}
+	}
+
+	if err := <-grpcErr; err != nil {
+		t.Errorf("in-flight gRPC request failed during shutdown: %v", err)
+	}
+	if got := <-httpRes; got != "done" {
+		t.Errorf("in-flight http request during shutdown = %q, want done", got)
+	}
+
+	select {
+	case err = <-runErr:
+		if err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("service did not stop")
+	}
+
+	if service.State() != StateStopped {
+		t.Errorf("State() = %s, want %s", service.State(), StateStopped)
+	}
+}
@@ -0,0 +1,103 @@
+package micro
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// State is a stage in the service lifecycle
+type State int32
+
+const (
+	// StateCreated indicates the service has been created but not initialized
+	StateCreated State = iota
+	// StateInitializing indicates connections to dependencies are being opened
+	StateInitializing
+	// StateMigrating indicates database migrations are running
+	StateMigrating
+	// StateServing indicates the service is ready and serving requests
+	StateServing
+	// StateDraining indicates the service has been asked to stop and is draining requests
+	StateDraining
+	// StateStopped indicates the service has stopped
+	StateStopped
+)
+
+var stateNames = map[State]string{
+	StateCreated:      "created",
+	StateInitializing: "initializing",
+	StateMigrating:    "migrating",
+	StateServing:      "serving",
+	StateDraining:     "draining",
+	StateStopped:      "stopped",
+}
+
+func (state State) String() string {
+	if name, ok := stateNames[state]; ok {
+		return name
+	}
+	return fmt.Sprintf("state(%d)", int32(state))
+}
+
+// State returns the current lifecycle state of the service
+func (service *Service) State() State {
+	return State(atomic.LoadInt32(&service.state))
+}
+
+func (service *Service) setState(state State) {
+	atomic.StoreInt32(&service.state, int32(state))
+}
+
+// Migrate runs fn while the service reports StateMigrating, restoring the previous state afterwards.
+// It is meant to be called from hooks that run before the service is ready.
+func (service *Service) Migrate(fn func() error) error {
+	prev := State(atomic.SwapInt32(&service.state, int32(StateMigrating)))
+	defer atomic.CompareAndSwapInt32(&service.state, int32(StateMigrating), int32(prev))
+
+	service.logger.Info("running database migrations")
+
+	return fn()
+}
+
+// SetAutoMigrator sets a migration that runs once before the service is reported as ready, ahead of other ready hooks.
+// The service reports StateMigrating while it runs. If the ready hooks have already run, the migration runs immediately.
+// Only one auto migrator can be set on a service.
+func (service *Service) SetAutoMigrator(fn func() error) error {
+	if fn == nil {
+		return errors.New("nil auto migrator not allowed")
+	}
+
+	service.hooksMu.Lock()
+
+	if service.autoMigrator {
+		service.hooksMu.Unlock()
+		return errors.New("auto migrator already set")
+	}
+	service.autoMigrator = true
+
+	ready := service.hooksRun[PhaseReady]
+	if !ready {
+		if service.hooks == nil {
+			service.hooks = make(map[HookPhase][]*Hook)
+		}
+		service.hooks[PhaseReady] = append(service.hooks[PhaseReady], &Hook{
+			Name:  "auto migration",
+			Order: math.MinInt32,
+			Fn: func(ctx context.Context) error {
+				return service.Migrate(fn)
+			},
+		})
+	}
+
+	service.hooksMu.Unlock()
+
+	if ready {
+		return service.Migrate(fn)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package micro
+
+import (
+	"context"
+	"crypto/tls"
+
+	"github.com/gidyon/micro/v2/utils/tlsutil"
+	"github.com/pkg/errors"
+)
+
+// initTLS loads the service certificate and builds the tls policy shared by the http and gRPC servers
+func (service *Service) initTLS(ctx context.Context) error {
+	if !service.cfg.ServiceTLSEnabled() {
+		return nil
+	}
+
+	var err error
+
+	service.certReloader, err = tlsutil.NewCertReloader(&tlsutil.CertReloaderOptions{
+		CertFile: service.cfg.ServiceTLSCertFile(),
+		KeyFile:  service.cfg.ServiceTLSKeyFile(),
+		Interval: service.cfg.ServiceTLSCertReloadInterval(),
+		OnReload: func() {
+			service.logger.Infoln("service tls certificate reloaded")
+		},
+		OnError: func(err error) {
+			service.logger.Errorf("failed to reload service tls certificate: %v", err)
+		},
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to load service tls certificate")
+	}
+
+	service.shutdowns = append(service.shutdowns, service.certReloader.Close)
+
+	minVersion, err := tlsutil.ParseVersion(service.cfg.ServiceTLSMinVersion())
+	if err != nil {
+		return err
+	}
+
+	cipherSuites, err := tlsutil.ParseCipherSuites(service.cfg.ServiceTLSCipherSuites())
+	if err != nil {
+		return err
+	}
+
+	clientAuth, err := tlsutil.ParseClientAuth(service.cfg.ServiceTLSClientAuth())
+	if err != nil {
+		return err
+	}
+
+	service.tlsConfig = &tls.Config{
+		NextProtos:     []string{"h2", "http/1.1"},
+		MinVersion:     minVersion,
+		MaxVersion:     tls.VersionTLS13,
+		CipherSuites:   cipherSuites,
+		ClientAuth:     clientAuth,
+		GetCertificate: service.certReloader.GetCertificate,
+	}
+
+	if clientAuth != tls.NoClientCert {
+		clientCAFile := service.cfg.ServiceTLSClientCAFile()
+		if clientCAFile == "" {
+			// Client certificates signed by the service certificate are accepted when no CA bundle is set
+			clientCAFile = service.cfg.ServiceTLSCertFile()
+		}
+		service.tlsConfig.ClientCAs, err = tlsutil.LoadCertPool(clientCAFile)
+		if err != nil {
+			return errors.Wrap(err, "failed to load client CA bundle")
+		}
+	}
+
+	return nil
+}
+
+// clientTLSConfig creates tls config for dialing a server whose certificate is signed by a CA in caFile.
+// The service certificate is presented to servers that request client authentication, callers dialing on
+// behalf of other clients must unset GetClientCertificate.
+func (service *Service) clientTLSConfig(caFile, serverName string) (*tls.Config, error) {
+	rootCAs, err := tlsutil.LoadCertPool(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		RootCAs:    rootCAs,
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if service.certReloader != nil {
+		tlsConfig.GetClientCertificate = service.certReloader.GetClientCertificate
+	}
+
+	return tlsConfig, nil
+}
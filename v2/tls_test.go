@@ -0,0 +1,296 @@
+package micro
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gidyon/micro/v2/internal/testutil"
+	"github.com/gidyon/micro/v2/pkg/config"
+	grpc_mw "github.com/gidyon/micro/v2/pkg/middleware/grpc"
+	"github.com/gidyon/micro/v2/utils/tlsutil"
+	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+)
+
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	keyPair tls.Certificate
+}
+
+func newTestCert(t *testing.T, commonName string, usage x509.ExtKeyUsage, parent *testCert) *testCert {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     []string{commonName},
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		keyPair: tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: cert},
+	}
+}
+
+func (c *testCert) write(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+
+	keyDER, err := x509.MarshalECPrivateKey(c.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, c.cert.Subject.CommonName+".pem")
+	keyFile = filepath.Join(dir, c.cert.Subject.CommonName+"-key.pem")
+
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile
+}
+
+func TestGatewayPeerIdentity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "micro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ca := newTestCert(t, "ca", x509.ExtKeyUsageAny, nil)
+	caFile, _ := ca.write(t, dir)
+	// The service certificate cannot be used for client authentication
+	certFile, keyFile := newTestCert(t, "localhost", x509.ExtKeyUsageServerAuth, ca).write(t, dir)
+	client := newTestCert(t, "client", x509.ExtKeyUsageClientAuth, ca)
+
+	rootCAs := x509.NewCertPool()
+	rootCAs.AddCert(ca.cert)
+
+	for _, mode := range []string{tlsutil.ClientAuthRequest, tlsutil.ClientAuthRequireAndVerify} {
+		t.Run(mode, func(t *testing.T) {
+			port := testutil.FreePort(t)
+
+			cfg, err := config.NewBuilder("test").
+				TLS(certFile, keyFile, "localhost").
+				HTTPort(port).
+				Set("security.clientAuth", mode).
+				Set("security.clientCA", caFile).
+				Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			service, err := NewService(context.Background(), cfg, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var (
+				mu       sync.Mutex
+				identity string
+			)
+
+			// Records the identity seen by gRPC handlers
+			service.AddGRPCUnaryServerInterceptors(func(
+				ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+			) (interface{}, error) {
+				mu.Lock()
+				identity = "anonymous"
+				if peer, ok := tlsutil.PeerIdentityFromContext(ctx); ok {
+					identity = peer.CommonName
+				}
+				mu.Unlock()
+				return handler(ctx, req)
+			})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			err = service.Init(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// Calls gRPC through the gateway like generated REST handlers
+			err = service.RuntimeMux().HandlePath(http.MethodGet, "/whoami", func(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+				ctx, err := runtime.AnnotateContext(r.Context(), service.RuntimeMux(), r, "/grpc.health.v1.Health/Check")
+				if err == nil {
+					_, err = healthpb.NewHealthClient(service.ClientConn()).Check(ctx, &healthpb.HealthCheckRequest{})
+				}
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusBadGateway)
+					return
+				}
+				mu.Lock()
+				fmt.Fprint(w, identity)
+				mu.Unlock()
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			runErr := make(chan error, 1)
+			go func() {
+				runErr <- service.Run(ctx)
+			}()
+
+			for start := time.Now(); service.State() != StateServing; time.Sleep(10 * time.Millisecond) {
+				if time.Since(start) > 5*time.Second {
+					t.Fatal("service not serving")
+				}
+			}
+
+			whoami := func(certs []tls.Certificate, header http.Header) string {
+				httpClient := &http.Client{Transport: &http.Transport{
+					TLSClientConfig: &tls.Config{RootCAs: rootCAs, Certificates: certs},
+				}}
+				req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("https://localhost:%d/whoami", port), nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header = header
+				res, err := httpClient.Do(req)
+				if err != nil {
+					return err.Error()
+				}
+				defer res.Body.Close()
+				bs, _ := ioutil.ReadAll(res.Body)
+				return string(bs)
+			}
+
+			got := whoami([]tls.Certificate{client.keyPair}, nil)
+			if got != "client" {
+				t.Errorf("gRPC identity of REST client with certificate = %q, want client", got)
+			}
+
+			if mode == tlsutil.ClientAuthRequest {
+				// Anonymous callers can neither borrow the service identity nor forward one themselves
+				spoofed := http.Header{
+					"Grpc-Metadata-" + grpc_mw.PeerCertificateKey: {base64.StdEncoding.EncodeToString(client.cert.Raw)},
+				}
+				got = whoami(nil, spoofed)
+				if got != "anonymous" {
+					t.Errorf("gRPC identity of anonymous REST client = %q, want anonymous", got)
+				}
+
+				cc, err := grpc.DialContext(ctx, fmt.Sprintf("localhost:%d", port),
+					grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: rootCAs})))
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer cc.Close()
+
+				mdCtx := metadata.AppendToOutgoingContext(ctx, grpc_mw.PeerCertificateKey, string(client.cert.Raw))
+				_, err = healthpb.NewHealthClient(cc).Check(mdCtx, &healthpb.HealthCheckRequest{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				mu.Lock()
+				if identity != "anonymous" {
+					t.Errorf("gRPC identity of anonymous gRPC client forwarding a certificate = %q, want anonymous", identity)
+				}
+				mu.Unlock()
+			}
+
+			cancel()
+
+			select {
+			case err = <-runErr:
+				if err != nil {
+					t.Errorf("Run() error = %v", err)
+				}
+			case <-time.After(10 * time.Second):
+				t.Fatal("service did not stop")
+			}
+		})
+	}
+}
+
+func TestInitTLSDefaults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "micro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := newTestCert(t, "localhost", x509.ExtKeyUsageServerAuth, nil).write(t, dir)
+
+	cfg, err := config.NewBuilder("test").TLS(certFile, keyFile, "localhost").HTTPort(testutil.FreePort(t)).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := NewService(context.Background(), cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = service.initTLS(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer service.certReloader.Close()
+
+	if service.tlsConfig.MinVersion != tls.VersionTLS10 {
+		t.Errorf("MinVersion = %x, want tls 1.0 when minVersion is unset", service.tlsConfig.MinVersion)
+	}
+	if service.tlsConfig.ClientAuth != tls.VerifyClientCertIfGiven {
+		t.Errorf("ClientAuth = %s, want %s when clientAuth is unset", service.tlsConfig.ClientAuth, tls.VerifyClientCertIfGiven)
+	}
+	if service.tlsConfig.ClientCAs == nil {
+		t.Error("ClientCAs not loaded from the service certificate")
+	}
+}
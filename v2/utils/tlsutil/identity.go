@@ -0,0 +1,54 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+)
+
+// PeerIdentity is the identity of a client whose certificate was verified during the tls handshake
+type PeerIdentity struct {
+	CommonName   string
+	Organization []string
+	DNSNames     []string
+	URIs         []string
+	Certificate  *x509.Certificate
+}
+
+type peerIdentityKey struct{}
+
+// PeerIdentityFromConnState returns the identity from the verified client certificate, or nil if none was verified
+func PeerIdentityFromConnState(state *tls.ConnectionState) *PeerIdentity {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	return PeerIdentityFromCertificate(state.VerifiedChains[0][0])
+}
+
+// PeerIdentityFromCertificate returns the identity in a client certificate which must have been verified by the caller
+func PeerIdentityFromCertificate(cert *x509.Certificate) *PeerIdentity {
+	uris := make([]string, 0, len(cert.URIs))
+	for _, uri := range cert.URIs {
+		uris = append(uris, uri.String())
+	}
+
+	return &PeerIdentity{
+		CommonName:   cert.Subject.CommonName,
+		Organization: cert.Subject.Organization,
+		DNSNames:     cert.DNSNames,
+		URIs:         uris,
+		Certificate:  cert,
+	}
+}
+
+// NewContextWithPeerIdentity returns a copy of ctx carrying the peer identity
+func NewContextWithPeerIdentity(ctx context.Context, identity *PeerIdentity) context.Context {
+	return context.WithValue(ctx, peerIdentityKey{}, identity)
+}
+
+// PeerIdentityFromContext returns the verified peer identity stored in ctx
+func PeerIdentityFromContext(ctx context.Context) (*PeerIdentity, bool) {
+	identity, ok := ctx.Value(peerIdentityKey{}).(*PeerIdentity)
+	return identity, ok && identity != nil
+}
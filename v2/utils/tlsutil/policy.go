@@ -0,0 +1,92 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	// ClientAuthNone does not request client certificates
+	ClientAuthNone = "none"
+	// ClientAuthRequest requests client certificates and verifies them if given
+	ClientAuthRequest = "request"
+	// ClientAuthRequireAndVerify requires clients to present a certificate signed by the client CA
+	ClientAuthRequireAndVerify = "require-and-verify"
+)
+
+// ClientAuthModes are the supported client authentication modes
+var ClientAuthModes = []string{ClientAuthNone, ClientAuthRequest, ClientAuthRequireAndVerify}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseVersion parses a tls version such as "1.2" or "TLS1.3". An empty version defaults to TLS 1.0
+func ParseVersion(version string) (uint16, error) {
+	version = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(version)), "TLS")
+	if version == "" {
+		return tls.VersionTLS10, nil
+	}
+	v, ok := tlsVersions[version]
+	if !ok {
+		return 0, fmt.Errorf("unknown tls version %q, supported versions are 1.0, 1.1, 1.2 and 1.3", version)
+	}
+	return v, nil
+}
+
+// ParseCipherSuites converts cipher suite names such as TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 to their ids.
+// Insecure cipher suites are rejected. No names returns nil so that Go defaults are used.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	suites := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		suites[suite.Name] = suite.ID
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := suites[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// ParseClientAuth converts a client authentication mode to its tls value. An empty mode defaults to request
+func ParseClientAuth(mode string) (tls.ClientAuthType, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case ClientAuthNone:
+		return tls.NoClientCert, nil
+	case "", ClientAuthRequest:
+		return tls.VerifyClientCertIfGiven, nil
+	case ClientAuthRequireAndVerify:
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return tls.NoClientCert, fmt.Errorf("unknown client auth mode %q, supported modes are %v", mode, ClientAuthModes)
+	}
+}
+
+// LoadCertPool reads a PEM encoded certificate bundle into a pool
+func LoadCertPool(file string) (*x509.CertPool, error) {
+	bs, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	certPool := x509.NewCertPool()
+	if !certPool.AppendCertsFromPEM(bs) {
+		return nil, fmt.Errorf("no certificates found in %s", file)
+	}
+	return certPool, nil
+}
@@ -0,0 +1,168 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertReloaderOptions contains options for creating a certificate reloader
+type CertReloaderOptions struct {
+	CertFile string
+	KeyFile  string
+	// Interval is how often the files are checked for changes. Zero disables polling, use Reload instead.
+	Interval time.Duration
+	// OnReload is called after the certificate has been reloaded successfully
+	OnReload func()
+	// OnError is called when reloading fails, the previously loaded certificate remains in use
+	OnError func(error)
+}
+
+// CertReloader serves a tls certificate loaded from files, reloading it when the files change.
+// Use its GetCertificate method in tls.Config so that rotated certificates are picked up without restarts.
+type CertReloader struct {
+	opt      *CertReloaderOptions
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	certStat fileStat
+	keyStat  fileStat
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type fileStat struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewCertReloader loads the certificate and starts polling the files for changes
+func NewCertReloader(opt *CertReloaderOptions) (*CertReloader, error) {
+	if opt == nil {
+		return nil, errors.New("nil cert reloader options not allowed")
+	}
+
+	reloader := &CertReloader{
+		opt:  opt,
+		stop: make(chan struct{}),
+	}
+
+	err := reloader.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	if opt.Interval > 0 {
+		go reloader.watch()
+	}
+
+	return reloader, nil
+}
+
+// Reload loads the certificate and key files, replacing the current certificate if they are valid
+func (reloader *CertReloader) Reload() error {
+	certStat, err := statFile(reloader.opt.CertFile)
+	if err != nil {
+		return err
+	}
+
+	keyStat, err := statFile(reloader.opt.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(reloader.opt.CertFile, reloader.opt.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load tls key pair: %w", err)
+	}
+
+	reloader.mu.Lock()
+	reloader.cert = &cert
+	reloader.certStat = certStat
+	reloader.keyStat = keyStat
+	reloader.mu.Unlock()
+
+	return nil
+}
+
+// GetCertificate returns the current certificate. It can be used as tls.Config GetCertificate
+func (reloader *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	reloader.mu.RLock()
+	defer reloader.mu.RUnlock()
+	return reloader.cert, nil
+}
+
+// GetClientCertificate returns the current certificate. It can be used as tls.Config GetClientCertificate
+// so that the certificate is presented to servers that require client authentication.
+func (reloader *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	return reloader.GetCertificate(nil)
+}
+
+// Close stops polling the files for changes
+func (reloader *CertReloader) Close() error {
+	reloader.stopOnce.Do(func() {
+		close(reloader.stop)
+	})
+	return nil
+}
+
+func (reloader *CertReloader) watch() {
+	ticker := time.NewTicker(reloader.opt.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-reloader.stop:
+			return
+		case <-ticker.C:
+		}
+
+		changed, err := reloader.changed()
+		if err == nil && !changed {
+			continue
+		}
+		if err == nil {
+			err = reloader.Reload()
+		}
+
+		switch {
+		case err != nil && reloader.opt.OnError != nil:
+			reloader.opt.OnError(err)
+		case err == nil && reloader.opt.OnReload != nil:
+			reloader.opt.OnReload()
+		}
+	}
+}
+
+// changed checks whether the certificate or key files were modified since they were last loaded
+func (reloader *CertReloader) changed() (bool, error) {
+	certStat, err := statFile(reloader.opt.CertFile)
+	if err != nil {
+		return false, err
+	}
+
+	keyStat, err := statFile(reloader.opt.KeyFile)
+	if err != nil {
+		return false, err
+	}
+
+	reloader.mu.RLock()
+	defer reloader.mu.RUnlock()
+
+	return !certStat.equal(reloader.certStat) || !keyStat.equal(reloader.keyStat), nil
+}
+
+func (fs fileStat) equal(other fileStat) bool {
+	return fs.modTime.Equal(other.modTime) && fs.size == other.size
+}
+
+// statFile follows symlinks so that atomic swaps of mounted secrets are detected
+func statFile(name string) (fileStat, error) {
+	info, err := os.Stat(name)
+	if err != nil {
+		return fileStat{}, err
+	}
+	return fileStat{modTime: info.ModTime(), size: info.Size()}, nil
+}
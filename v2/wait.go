@@ -0,0 +1,155 @@
+package micro
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gidyon/micro/v2/pkg/config"
+	"github.com/gidyon/micro/v2/pkg/conn"
+	"github.com/pkg/errors"
+	"go.uber.org/multierr"
+)
+
+// dependencyProbeTimeout is the maximum duration of a single probe attempt
+const dependencyProbeTimeout = 5 * time.Second
+
+type dependencyProbe struct {
+	kind  string
+	name  string
+	opts  *config.WaitOptions
+	probe func(ctx context.Context) error
+}
+
+// waitForDependencies probes the opened databases and external services concurrently until each is available.
+// It fails with a report of every dependency that did not come up within its maximum wait.
+func (service *Service) waitForDependencies(ctx context.Context) error {
+	probes := make([]*dependencyProbe, 0)
+
+	for _, dbInfo := range service.cfg.Databases() {
+		name := dbInfo.Metadata().Name()
+
+		switch dbInfo.Type {
+		case config.SQLDBType:
+			sqlDB, ok := service.sqlDBs[name]
+			if !ok {
+				continue
+			}
+			probes = append(probes, &dependencyProbe{
+				kind:  "sql database",
+				name:  name,
+				opts:  dbInfo.WaitOptions(),
+				probe: sqlDB.PingContext,
+			})
+		case config.RedisDBType:
+			redisClient, ok := service.redisClients[name]
+			if !ok {
+				continue
+			}
+			probes = append(probes, &dependencyProbe{
+				kind: "redis database",
+				name: name,
+				opts: dbInfo.WaitOptions(),
+				probe: func(ctx context.Context) error {
+					return redisClient.Ping(ctx).Err()
+				},
+			})
+		}
+	}
+
+	for _, srv := range service.cfg.ExternalServices() {
+		cc, ok := service.externalServicesConn[strings.ToLower(srv.Name())]
+		if !ok {
+			continue
+		}
+		probes = append(probes, &dependencyProbe{
+			kind: "external service",
+			name: srv.Name(),
+			opts: srv.WaitOptions(),
+			probe: func(ctx context.Context) error {
+				return conn.WaitForConnReady(ctx, cc)
+			},
+		})
+	}
+
+	var (
+		mu   = &sync.Mutex{}
+		wg   = &sync.WaitGroup{}
+		errs error
+	)
+
+	for _, probe := range probes {
+		wg.Add(1)
+
+		go func(probe *dependencyProbe) {
+			defer wg.Done()
+
+			err := service.waitForDependency(ctx, probe)
+			if err != nil {
+				mu.Lock()
+				errs = multierr.Append(errs, err)
+				mu.Unlock()
+			}
+		}(probe)
+	}
+
+	wg.Wait()
+
+	return errors.Wrap(errs, "required dependencies are not available")
+}
+
+// waitForDependency retries the probe with exponential backoff and jitter until it succeeds or the maximum wait elapses
+func (service *Service) waitForDependency(ctx context.Context, dep *dependencyProbe) error {
+	var (
+		start   = time.Now()
+		backoff = dep.opts.InitialBackoff()
+		err     error
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, dep.opts.MaxWait())
+	defer cancel()
+
+	for attempt := 1; ; attempt++ {
+		probeErr := probeOnce(ctx, dep.probe)
+		if probeErr == nil {
+			service.logger.Infof("[%s AVAILABLE] [name: %s] [attempts: %d]", strings.ToUpper(dep.kind), dep.name, attempt)
+			return nil
+		}
+
+		// Keep the underlying failure rather than the deadline error when the maximum wait interrupts a probe
+		if err == nil || ctx.Err() == nil {
+			err = probeErr
+		}
+
+		// Equal jitter keeps retries spread out while still growing exponentially
+		delay := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+
+		service.logger.Warningf(
+			"[%s NOT AVAILABLE] [name: %s] [attempt: %d] [retry in: %s]: %v",
+			strings.ToUpper(dep.kind), dep.name, attempt, delay.Round(time.Millisecond), err,
+		)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf(
+				"%s %q not available after %d attempts in %s: %v",
+				dep.kind, dep.name, attempt, time.Since(start).Round(time.Millisecond), err,
+			)
+		case <-time.After(delay):
+		}
+
+		backoff *= 2
+		if backoff > dep.opts.MaxBackoff() {
+			backoff = dep.opts.MaxBackoff()
+		}
+	}
+}
+
+func probeOnce(ctx context.Context, probe func(context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, dependencyProbeTimeout)
+	defer cancel()
+	return probe(ctx)
+}